@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/token"
 	"strings"
 )
@@ -9,6 +10,8 @@ import (
 type Node interface {
 	// Return the literal string of the node.
 	TokenLiteral() string
+	// Return the position of the token which represents the node.
+	Pos() token.Position
 	// Return the string representation of the node and its children.
 	String() string
 }
@@ -38,6 +41,14 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	} else {
+		return token.Position{}
+	}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -49,6 +60,7 @@ func (p *Program) String() string {
 }
 
 // let <name> = <value>;
+// const <name> = <value>;
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
@@ -57,6 +69,7 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Position }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -79,6 +92,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Position }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -91,6 +105,101 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// throw <value>;
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Position }
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+
+	out.WriteString(";")
+	return out.String()
+}
+
+// while (<condition>) <body>
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Position }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// for (<variable> in <iterable>) <body>
+type ForInStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) Pos() token.Position  { return fs.Token.Position }
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// break;
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Position }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// continue;
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Position }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+// export <let-statement>
+type ExportStatement struct {
+	Token     token.Token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Position }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
 // <expression>;
 type ExpressionStatement struct {
 	Token token.Token
@@ -99,6 +208,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Position }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -114,6 +224,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Position }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -131,6 +242,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Position }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -147,16 +259,23 @@ func (al *ArrayLiteral) String() string {
 
 type HashLiteral struct {
 	Token token.Token
-	Pairs map[Expression]Expression
+	// Pairs in the source order
+	Pairs []HashLiteralPair
+}
+
+type HashLiteralPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Position }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -174,6 +293,7 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -187,6 +307,37 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// <expression>[<expression>:<expression>]
+// Both of the bounds are optional.
+type SliceExpression struct {
+	Token token.Token
+	Left  Expression
+	Start Expression
+	End   Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Token.Position }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("]")
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // "<string>"
 type StringLiteral struct {
 	Token token.Token
@@ -195,8 +346,36 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Position }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// "<string>${<expression>}<string>..."
+type InterpolatedString struct {
+	// The `StringHead` token
+	Token token.Token
+	// String literals and embedded expressions in the order of appearance
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Position }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
 // <identifier>
 type Identifier struct {
 	Token token.Token
@@ -205,18 +384,33 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Position }
 func (i *Identifier) String() string       { return i.Value }
 
 // <integer-literral>
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	// Set instead of Value when the literal does not fit in int64.
+	Big *big.Int
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Position }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// <float-literal>
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Position }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // <operator> <right>
 type PrefixExpression struct {
 	Token    token.Token
@@ -226,6 +420,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Position }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -246,6 +441,7 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -257,6 +453,29 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// <target> <operator> <value>
+// where <target> is an identifier or an index expression and <operator> is one of `=`, `+=`, `-=`, `*=` and `/=`.
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Token.Position }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // if (<condition>) <consequence> (else <alternative>)
 type IfExpression struct {
 	Token       token.Token
@@ -267,6 +486,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -280,6 +500,48 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// try <block> (catch (<parameter>) <catch-block>) (finally <finally-block>)
+type TryExpression struct {
+	Token      token.Token
+	Block      *BlockStatement
+	CatchParam *Identifier
+	CatchBlock *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Position }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	if te.CatchBlock != nil {
+		out.WriteString(" catch(")
+		out.WriteString(te.CatchParam.String())
+		out.WriteString(") ")
+		out.WriteString(te.CatchBlock.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
+// import <path>
+type ImportExpression struct {
+	Token token.Token
+	Path  Expression
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) Pos() token.Position  { return ie.Token.Position }
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " " + ie.Path.String()
+}
+
 // true / false
 type Boolean struct {
 	Token token.Token
@@ -288,34 +550,67 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Position }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 // fn(<parameter>*) <body>
 type FunctionLiteral struct {
-	Token      token.Token
+	Token token.Token
+	// The name bound by `let`. It is empty for anonymous functions.
+	Name       string
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Default values of `Parameters` (e.g. `10` of `b = 10`). An element is nil if the parameter has no default.
+	// Parameters with defaults always follow the ones without them.
+	Defaults []Expression
+	// The parameter which collects extra arguments (e.g. `rest` of `...rest`). It is nil if absent.
+	Rest *Identifier
+	Body *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Position }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ParametersString(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// Return the parameter list like `a, b = 10, ...rest`.
+func ParametersString(parameters []*Identifier, defaults []Expression, rest *Identifier) string {
+	params := []string{}
+	for i, p := range parameters {
+		if i < len(defaults) && defaults[i] != nil {
+			params = append(params, p.String()+" = "+defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+	return strings.Join(params, ", ")
+}
+
+// ...<value> in arguments of a call
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Position }
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
 // <function>(<argument>*)
 type CallExpression struct {
 	Token     token.Token
@@ -325,6 +620,7 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Position }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
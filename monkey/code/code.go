@@ -0,0 +1,325 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"monkey/token"
+	"sort"
+)
+
+// A sequence of encoded instructions.
+type Instructions []byte
+
+// Return the human-readable disassembly of the instructions.
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := LookUp(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i += 1
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// A kind of an instruction. It is always encoded as the first byte of an instruction.
+type Opcode byte
+
+// Enumeration constants for `Opcode`
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+
+	OpTrue
+	OpFalse
+	OpNull
+	OpDup
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+
+	OpMinus
+	OpBang
+
+	OpJumpNotTruthy
+	OpJump
+	OpJumpIfPassed
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpGetFree
+	OpSetFree
+	OpCurrentClosure
+	OpCaptureGlobal
+	OpCaptureLocal
+	OpCaptureFree
+	OpClearGlobals
+	OpClearLocals
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+	OpSlice
+	OpInterpolate
+
+	OpIterate
+	OpIterNext
+
+	OpCall
+	OpCallSpread
+	OpReturnValue
+	OpReturn
+	OpClosure
+
+	OpThrow
+	OpTry
+	OpPopTry
+	OpCatch
+	OpRethrow
+
+	OpImport
+)
+
+// The name and the operand layout of an opcode.
+type Definition struct {
+	Name string
+	// Byte width of each operand.
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpBitAnd:     {"OpBitAnd", []int{}},
+	OpBitOr:      {"OpBitOr", []int{}},
+	OpBitXor:     {"OpBitXor", []int{}},
+	OpShiftLeft:  {"OpShiftLeft", []int{}},
+	OpShiftRight: {"OpShiftRight", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+	// The operand is the number of elements on the top of the stack to be pushed again.
+	OpDup: {"OpDup", []int{1}},
+
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+	// Jump to the second operand if the argument for the local of the first operand is passed.
+	// It skips the evaluation of the default value of a parameter.
+	OpJumpIfPassed: {"OpJumpIfPassed", []int{1, 2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpSetFree:        {"OpSetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	// Push the cell of a variable to be captured by `OpClosure`.
+	// A global or a local is moved into a new cell when it is captured first.
+	OpCaptureGlobal: {"OpCaptureGlobal", []int{2}},
+	OpCaptureLocal:  {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:   {"OpCaptureFree", []int{1}},
+	// Clear the slots of the variables of a block so that it binds fresh ones (e.g. in each
+	// iteration of `for-in`). The operands are the first slot and the number of slots.
+	OpClearGlobals: {"OpClearGlobals", []int{2, 2}},
+	OpClearLocals:  {"OpClearLocals", []int{1, 1}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+	// Store the value on the top of the stack into `<left>[<index>]` below it, and push the value.
+	OpSetIndex: {"OpSetIndex", []int{}},
+	OpSlice:    {"OpSlice", []int{}},
+	// The operand is the number of parts of an interpolated string.
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	// Replace the iterable on the top of the stack with an iterator over its items.
+	OpIterate: {"OpIterate", []int{}},
+	// Push the next item of the iterator on the top of the stack,
+	// or jump to the operand if the iterator is exhausted.
+	OpIterNext: {"OpIterNext", []int{2}},
+
+	OpCall: {"OpCall", []int{1}},
+	// The operand is the number of arrays which are concatenated into the arguments.
+	OpCallSpread:  {"OpCallSpread", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	// The first operand is the constant index of the function,
+	// the second one is the number of free variables.
+	OpClosure: {"OpClosure", []int{2, 1}},
+
+	OpThrow: {"OpThrow", []int{}},
+	// The operand is the position of the handler, which receives the raised error on the stack.
+	OpTry:    {"OpTry", []int{2}},
+	OpPopTry: {"OpPopTry", []int{}},
+	// Replace the raised error with the value bound by `catch`
+	OpCatch:   {"OpCatch", []int{}},
+	OpRethrow: {"OpRethrow", []int{}},
+
+	// Replace the path on the top of the stack with the module. The operand is the constant index
+	// of the name of the importing file, which relative paths are resolved against.
+	OpImport: {"OpImport", []int{2}},
+}
+
+// Return the definition of the opcode `op`.
+func LookUp(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Encode an instruction. Operands are stored in big endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// Decode operands of an instruction. Return the operands and the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// Source positions of instructions ordered by offsets. An entry applies to
+// the instructions from its offset up to the next entry.
+type SourceMap []SourceMapEntry
+
+type SourceMapEntry struct {
+	// The offset of the first instruction
+	Offset int
+	Pos    token.Position
+}
+
+// Record that the instructions from `offset` come from `pos`.
+// Entries at or after `offset` are replaced since the instructions were rewritten.
+func (sm SourceMap) Add(offset int, pos token.Position) SourceMap {
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset >= offset })
+	sm = sm[:i]
+	if i > 0 && sm[i-1].Pos == pos {
+		return sm
+	}
+	return append(sm, SourceMapEntry{Offset: offset, Pos: pos})
+}
+
+// Return the position of the instruction at `offset`. It is invalid if unknown.
+func (sm SourceMap) Lookup(offset int) token.Position {
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return sm[i-1].Pos
+}
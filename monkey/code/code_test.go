@@ -0,0 +1,103 @@
+package code
+
+import (
+	"testing"
+
+	"monkey/token"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+
+	for _, tt := range tests {
+		a.Equal(tt.expected, Make(tt.op, tt.operands...))
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	a := assert.New(t)
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	a.Equal(expected, concatted.String())
+}
+
+func TestReadOperands(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := LookUp(byte(tt.op))
+		if !a.NoError(err) {
+			continue
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		a.Equal(tt.bytesRead, n)
+		a.Equal(tt.operands, operandsRead)
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	a := assert.New(t)
+	first := token.Position{Line: 1, Column: 1}
+	second := token.Position{Line: 2, Column: 5}
+
+	var sm SourceMap
+	sm = sm.Add(0, first)
+	sm = sm.Add(3, first)
+	sm = sm.Add(4, second)
+	a.Len(sm, 2)
+
+	a.Equal(first, sm.Lookup(0))
+	a.Equal(first, sm.Lookup(3))
+	a.Equal(second, sm.Lookup(4))
+	a.Equal(second, sm.Lookup(10))
+
+	// Rewriting instructions replaces the later entries
+	sm = sm.Add(3, second)
+	a.Equal(first, sm.Lookup(2))
+	a.Equal(second, sm.Lookup(3))
+	a.Len(sm, 2)
+
+	a.False(SourceMap(nil).Lookup(0).IsValid())
+}
@@ -0,0 +1,924 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+// The output of the compiler which is fed to `vm`.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// Source positions of the instructions and the functions called by `OpCall`
+	Positions code.SourceMap
+	CallSites code.SourceMap
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// Instructions emitted for one function body (or the main program).
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	positions code.SourceMap
+	callSites code.SourceMap
+
+	// `try` expressions whose handlers are installed while the instructions being compiled
+	// run, from the outermost one
+	tries []*ast.TryExpression
+	// Loops enclosing the instructions being compiled, from the outermost one
+	loops []*loopScope
+	// The number of values held on the stack across statements
+	// (e.g. the iterator of `for-in` and the error being raised while `finally` runs)
+	held int
+}
+
+// Jump targets of `break` and `continue` in a loop.
+type loopScope struct {
+	// The position where `continue` jumps to
+	start int
+	// Positions of the jumps of `break`, which are back-patched at the end of the loop
+	breaks []int
+	// The numbers of the `try` expressions and the held values outside of the body
+	tries int
+	held  int
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// The position of the node being compiled, which emitted instructions are attributed to
+	position token.Position
+}
+
+func New() *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{{}},
+		scopeIndex:  0,
+	}
+}
+
+// Create a compiler which continues from the previous compilation (e.g. in REPL).
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// Create a symbol table with all builtin functions defined.
+func NewGlobalSymbolTable() *SymbolTable {
+	return New().symbolTable
+}
+
+// Return the compiled instructions and constants.
+func (c *Compiler) Bytecode() *Bytecode {
+	scope := c.scopes[c.scopeIndex]
+	return &Bytecode{
+		Instructions: scope.instructions,
+		Constants:    c.constants,
+		Positions:    scope.positions,
+		CallSites:    scope.callSites,
+	}
+}
+
+// Compile `node` and append the result to the current scope.
+func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		outer := c.position
+		c.position = pos
+		defer func() { c.position = outer }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+		// Statements other than expressions leave `null` as the value of the program like the evaluator does.
+		if len(node.Statements) > 0 {
+			switch node.Statements[len(node.Statements)-1].(type) {
+			case *ast.LetStatement, *ast.ExportStatement, *ast.WhileStatement, *ast.ForInStatement:
+				c.emit(code.OpNull)
+				c.emit(code.OpPop)
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		if symbol, ok := c.symbolTable.lookUpLocal(node.Name.Value); ok && symbol.Const {
+			return fmt.Errorf("cannot assign to constant: %s", node.Name.Value)
+		}
+		// Define the symbol first so that the function can refer to itself
+		var symbol Symbol
+		if node.Token.Kind == token.Const {
+			symbol = c.symbolTable.DefineConst(node.Name.Value)
+		} else {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			if err := c.compileFunctionLiteral(fn, node.Name.Value); err != nil {
+				return err
+			}
+		} else if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.ExportStatement:
+		// Exported bindings are collected from the globals after the module runs
+		return c.Compile(node.Statement)
+	case *ast.ImportExpression:
+		if err := c.Compile(node.Path); err != nil {
+			return err
+		}
+		importer := &object.String{Value: node.Pos().Filename}
+		c.emit(code.OpImport, c.addConstant(importer))
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.leaveTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
+	case *ast.BreakStatement:
+		loop, err := c.leaveLoopBody("break")
+		if err != nil {
+			return err
+		}
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop, err := c.leaveLoopBody("continue")
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loop.start)
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		return c.emitInfixOperator(node.Operator)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInteger{Value: node.Big}
+		}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.Identifier:
+		// Unlike the evaluator, undefined identifiers are reported at compile time
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+			} else if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+	case *ast.CallExpression:
+		return c.compileCallExpression(node)
+	default:
+		return fmt.Errorf("unsupported node: %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) emitInfixOperator(operator string) error {
+	switch operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "&":
+		c.emit(code.OpBitAnd)
+	case "|":
+		c.emit(code.OpBitOr)
+	case "^":
+		c.emit(code.OpBitXor)
+	case "<<":
+		c.emit(code.OpShiftLeft)
+	case ">>":
+		c.emit(code.OpShiftRight)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
+	case ">=":
+		c.emit(code.OpGreaterEqual)
+	case "<=":
+		c.emit(code.OpLessEqual)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("unknown operator %s", operator)
+	}
+	return nil
+}
+
+func (c *Compiler) compileCallExpression(node *ast.CallExpression) error {
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+
+	spread := false
+	for _, a := range node.Arguments {
+		if _, ok := a.(*ast.SpreadExpression); ok {
+			spread = true
+		}
+	}
+
+	for _, a := range node.Arguments {
+		if s, ok := a.(*ast.SpreadExpression); ok {
+			if err := c.Compile(s.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.Compile(a); err != nil {
+			return err
+		}
+		if spread {
+			// Wrap the argument so that all of the arguments can be concatenated
+			c.emit(code.OpArray, 1)
+		}
+	}
+
+	var pos int
+	if spread {
+		pos = c.emit(code.OpCallSpread, len(node.Arguments))
+	} else {
+		pos = c.emit(code.OpCall, len(node.Arguments))
+	}
+	// Errors propagating from the callee are traced back to the function expression like the evaluator does
+	c.scopes[c.scopeIndex].callSites = c.scopes[c.scopeIndex].callSites.Add(pos, node.Function.Pos())
+	return nil
+}
+
+// Compile an assignment, which leaves the assigned value on the stack.
+// A compound assignment (e.g. `+=`) reads the current value before evaluating the right side.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.resolveAssignee(target.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("identifier not found: %s", target.Value)
+		}
+		if symbol.Const {
+			return fmt.Errorf("cannot assign to constant: %s", target.Value)
+		}
+
+		if node.Operator != "=" {
+			c.loadSymbol(symbol)
+		}
+		if err := c.compileAssignedValue(node); err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if node.Operator != "=" {
+			c.emit(code.OpDup, 2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.compileAssignedValue(node); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex)
+	default:
+		return fmt.Errorf("unsupported assignment target: %T", target)
+	}
+	return nil
+}
+
+// Compile the right side of an assignment. The operator of a compound assignment is
+// applied to the current value, which is on the stack.
+func (c *Compiler) compileAssignedValue(node *ast.AssignExpression) error {
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	if node.Operator == "=" {
+		return nil
+	}
+	// Strip the trailing "="
+	return c.emitInfixOperator(node.Operator[:len(node.Operator)-1])
+}
+
+// Compile `&&` and `||` so that the right operand is skipped if the left one decides the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	// `&&` jumps to false if the left operand is falsy, `||` jumps to true if it is truthy
+	if node.Operator == "||" {
+		c.emit(code.OpBang)
+	}
+	jumpLeftPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	jumpRightPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	truePos := c.emit(code.OpTrue)
+	jumpEndPos := c.emit(code.OpJump, 9999)
+	falsePos := c.emit(code.OpFalse)
+	c.changeOperand(jumpEndPos, len(c.currentInstructions()))
+
+	c.changeOperand(jumpRightPos, falsePos)
+	if node.Operator == "||" {
+		c.changeOperand(jumpLeftPos, truePos)
+	} else {
+		c.changeOperand(jumpLeftPos, falsePos)
+	}
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit with a bogus operand. It is back-patched after the consequence is compiled.
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// Compile `try`. An error raised in the block is passed to the handler installed by `OpTry`,
+// and `finally` is compiled into every path which leaves the expression.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	c.pushTry(node)
+	tryPos := c.emit(code.OpTry, 9999)
+	if err := c.compileBlockValue(node.Block); err != nil {
+		return err
+	}
+	c.emit(code.OpPopTry)
+	c.popTry()
+	if err := c.compileFinally(node); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+
+	if node.CatchBlock == nil {
+		if err := c.compileRethrow(node); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+
+	// Errors raised in `catch` also run `finally` before propagating
+	finallyPos := -1
+	if node.Finally != nil {
+		finallyPos = c.emit(code.OpTry, 9999)
+		c.pushTry(node)
+	}
+	if err := c.compileCatch(node); err != nil {
+		return err
+	}
+	if node.Finally == nil {
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+	c.emit(code.OpPopTry)
+	c.popTry()
+	if err := c.compileFinally(node); err != nil {
+		return err
+	}
+	catchJumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(finallyPos, len(c.currentInstructions()))
+	if err := c.compileRethrow(node); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	c.changeOperand(catchJumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// Compile `catch` which receives the raised error on the stack.
+// The parameter is only visible in the block.
+func (c *Compiler) compileCatch(node *ast.TryExpression) error {
+	c.emit(code.OpCatch)
+
+	clearPos := c.enterBlock()
+	symbol := c.symbolTable.Define(node.CatchParam.Value)
+	c.storeSymbol(symbol)
+	if err := c.compileBlockValue(node.CatchBlock); err != nil {
+		return err
+	}
+	c.leaveBlock(clearPos)
+	return nil
+}
+
+// Run `finally` of `node` and raise the error on the stack again.
+func (c *Compiler) compileRethrow(node *ast.TryExpression) error {
+	c.scopes[c.scopeIndex].held += 1
+	if err := c.compileFinally(node); err != nil {
+		return err
+	}
+	c.scopes[c.scopeIndex].held -= 1
+	c.emit(code.OpRethrow)
+	return nil
+}
+
+// Compile `finally` of `node` if any. Its value is discarded.
+func (c *Compiler) compileFinally(node *ast.TryExpression) error {
+	if node.Finally == nil {
+		return nil
+	}
+	return c.Compile(node.Finally)
+}
+
+func (c *Compiler) pushTry(node *ast.TryExpression) {
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, node)
+}
+
+func (c *Compiler) popTry() {
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+}
+
+// Emit the instructions which leave the enclosing `try` expressions of the function
+// down to the `depth`-th one (e.g. before `return`): handlers are removed and
+// `finally` blocks are run from the innermost one.
+func (c *Compiler) leaveTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= depth; i-- {
+		// `finally` is outside of its `try`
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		c.emit(code.OpPopTry)
+		if err := c.compileFinally(tries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.enterLoop(start)
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, end)
+	c.leaveLoop(end)
+	return nil
+}
+
+// Compile `for (<variable> in <iterable>) { <body> }`. The iterator is held on the stack during the loop,
+// and the variable and the definitions in the body are bound afresh in each iteration.
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIterate)
+	c.scopes[c.scopeIndex].held += 1
+
+	start := len(c.currentInstructions())
+	iterNextPos := c.emit(code.OpIterNext, 9999)
+	clearPos := c.enterBlock()
+	symbol := c.symbolTable.Define(node.Variable.Value)
+	c.storeSymbol(symbol)
+
+	c.enterLoop(start)
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	c.leaveBlock(clearPos)
+
+	// Both the exhausted iterator and `break` discard the iterator
+	c.scopes[c.scopeIndex].held -= 1
+	end := c.emit(code.OpPop)
+	c.changeOperand(iterNextPos, end)
+	c.leaveLoop(end)
+	return nil
+}
+
+func (c *Compiler) enterLoop(start int) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loopScope{start: start, tries: len(scope.tries), held: scope.held})
+}
+
+// Leave the innermost loop and make its `break` jump to `end`.
+func (c *Compiler) leaveLoop(end int) {
+	scope := &c.scopes[c.scopeIndex]
+	loop := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, end)
+	}
+}
+
+// Emit the instructions which leave the body of the innermost loop by `statement` (`break` or `continue`):
+// `finally` blocks in the body are run and the values held in it are discarded.
+func (c *Compiler) leaveLoopBody(statement string) (*loopScope, error) {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil, fmt.Errorf("%s outside of loop", statement)
+	}
+
+	loop := loops[len(loops)-1]
+	if err := c.leaveTries(loop.tries); err != nil {
+		return nil, err
+	}
+	for i := loop.held; i < c.scopes[c.scopeIndex].held; i++ {
+		c.emit(code.OpPop)
+	}
+	return loop, nil
+}
+
+// Enter a block whose variables are only visible in it, and emit the instruction which clears
+// their slots. It returns the position of the instruction, which is completed by `leaveBlock`.
+func (c *Compiler) enterBlock() int {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+
+	owner := c.symbolTable.owner()
+	if owner.Outer == nil {
+		return c.emit(code.OpClearGlobals, owner.numDefinitions, 0)
+	}
+	return c.emit(code.OpClearLocals, owner.numDefinitions, 0)
+}
+
+// Leave the block entered by `enterBlock`. The slots defined in it (including those of
+// nested blocks) are cleared by the instruction at `clearPos`.
+func (c *Compiler) leaveBlock(clearPos int) {
+	ins := c.currentInstructions()
+	def, _ := code.LookUp(ins[clearPos])
+	operands, _ := code.ReadOperands(def, ins[clearPos+1:])
+
+	first := operands[0]
+	c.changeOperand(clearPos, first, c.symbolTable.owner().numDefinitions-first)
+	c.symbolTable = c.symbolTable.Outer
+}
+
+// Compile a block which leaves its value on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) && endsWithExpression(block) {
+		c.removeLastPop()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// Judge if the value of `block` is its last expression statement.
+// Other statements (e.g. `for-in`) may also end with OpPop.
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+	numDefaults := 0
+	for i, p := range node.Parameters {
+		if i >= len(node.Defaults) || node.Defaults[i] == nil {
+			c.symbolTable.Define(p.Value)
+			continue
+		}
+
+		// Evaluate the default only if the argument is omitted. The parameter is defined
+		// afterward so that the default can refer to the preceding parameters only.
+		numDefaults += 1
+		jumpPos := c.emit(code.OpJumpIfPassed, i, 9999)
+		if err := c.Compile(node.Defaults[i]); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(p.Value)
+		c.emit(code.OpSetLocal, symbol.Index)
+		c.changeOperand(jumpPos, i, len(c.currentInstructions()))
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) && endsWithExpression(node.Body) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	scope := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   numDefaults,
+		Variadic:      node.Rest != nil,
+		Name:          node.Name,
+		Positions:     scope.positions,
+		CallSites:     scope.callSites,
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// Push the variable `s` to be captured by a closure. Variables are shared through cells
+// so that assignments are visible to every closure.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpCaptureGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// Append an instruction to the current scope and return its position.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.scopes[c.scopeIndex].positions = c.scopes[c.scopeIndex].positions.Add(pos, c.position)
+
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	copy(ins[pos:], newInstruction)
+}
+
+// Rewrite the operands of the instruction at `opPos`.
+func (c *Compiler) changeOperand(opPos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operands...)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex += 1
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() CompilationScope {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex -= 1
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope
+}
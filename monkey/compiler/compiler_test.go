@@ -0,0 +1,545 @@
+package compiler
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestConditionals(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0008
+				code.Make(code.OpTrue),
+				// 0009
+				code.Make(code.OpJump, 13),
+				// 0012
+				code.Make(code.OpFalse),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpBang),
+				// 0002
+				code.Make(code.OpJumpNotTruthy, 9),
+				// 0005
+				code.Make(code.OpFalse),
+				// 0006
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0009
+				code.Make(code.OpTrue),
+				// 0010
+				code.Make(code.OpJump, 14),
+				// 0013
+				code.Make(code.OpFalse),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "let one = 1; one;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let one = 1;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestClosures(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input: "let wrapper = fn() { let countDown = fn(x) { countDown(x - 1); }; };",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSub),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 1, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestDefaultParameters(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input: "fn(a, b = 1, ...c) { a + b }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfPassed, 1, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestSpreadArguments(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "let f = 1; let xs = 2; f(3, ...xs)",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpCallSpread, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestAssignExpressions(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			// Captured variables are assigned through their cells
+			input: "fn() { let c = 0; fn() { c += 1 } }",
+			expectedConstants: []interface{}{
+				0,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] += 2",
+			expectedConstants: []interface{}{1, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestTryExpressions(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "try { throw 1 } catch (e) { e }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 12),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpThrow),
+				// 0007
+				code.Make(code.OpNull),
+				// 0008
+				code.Make(code.OpPopTry),
+				// 0009
+				code.Make(code.OpJump, 24),
+				// 0012
+				code.Make(code.OpCatch),
+				// 0013
+				code.Make(code.OpClearGlobals, 0, 1),
+				// 0018
+				code.Make(code.OpSetGlobal, 0),
+				// 0021
+				code.Make(code.OpGetGlobal, 0),
+				// 0024
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// `finally` is compiled into both of the normal path and the handler
+			input:             "try { 1 } finally { 2 }",
+			expectedConstants: []interface{}{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 14),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpPopTry),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpPop),
+				// 0011
+				code.Make(code.OpJump, 19),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpRethrow),
+				// 0019
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestLoops(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			input:             "while (true) { break }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpJump, 10),
+				// 0007
+				code.Make(code.OpJump, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// The variable is cleared in each iteration so that closures capture a fresh one
+			input:             "for (x in [1]) { continue }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIterate),
+				// 0007
+				code.Make(code.OpIterNext, 24),
+				// 0010
+				code.Make(code.OpClearGlobals, 0, 1),
+				// 0015
+				code.Make(code.OpSetGlobal, 0),
+				// 0018
+				code.Make(code.OpJump, 7),
+				// 0021
+				code.Make(code.OpJump, 7),
+				// 0024
+				code.Make(code.OpPop),
+				// 0025
+				code.Make(code.OpNull),
+				// 0026
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestImportExpressions(t *testing.T) {
+	runCompilerTests(t, []compilerTestCase{
+		{
+			// The second constant is the name of the importing file
+			input:             `export let m = import "m.mk"`,
+			expectedConstants: []interface{}{"m.mk", ""},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpImport, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestSymbolTableResolveFree(t *testing.T) {
+	a := assert.New(t)
+
+	global := NewSymbolTable()
+	global.Define("a")
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !a.True(ok) {
+			continue
+		}
+		a.Equal(sym, result)
+	}
+	a.Equal([]Symbol{{Name: "b", Scope: LocalScope, Index: 0}}, secondLocal.FreeSymbols)
+}
+
+func TestSymbolTableBlock(t *testing.T) {
+	a := assert.New(t)
+
+	global := NewSymbolTable()
+	global.Define("a")
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+	block := NewBlockSymbolTable(local)
+	c := block.Define("c")
+	d := local.Define("d")
+
+	// Symbols of a block are stored in the slots of the function
+	a.Equal(Symbol{Name: "c", Scope: LocalScope, Index: 1, block: true}, c)
+	a.Equal(Symbol{Name: "d", Scope: LocalScope, Index: 2}, d)
+	b, ok := block.Resolve("b")
+	if a.True(ok) {
+		a.Equal(Symbol{Name: "b", Scope: LocalScope, Index: 0}, b)
+	}
+	_, ok = local.Resolve("c")
+	a.False(ok)
+	a.Empty(block.FreeSymbols)
+
+	// A variable of a block is captured by closures even if it is global
+	globalBlock := NewBlockSymbolTable(global)
+	x := globalBlock.Define("x")
+	a.Equal(GlobalScope, x.Scope)
+	fn := NewEnclosedSymbolTable(globalBlock)
+	for _, name := range []string{"a", "x"} {
+		_, ok := fn.Resolve(name)
+		a.True(ok, name)
+	}
+	a.Equal([]Symbol{x}, fn.FreeSymbols)
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	a := assert.New(t)
+	for _, tt := range tests {
+		program := parse(a, tt.input)
+
+		compiler := New()
+		if !a.NoError(compiler.Compile(program)) {
+			continue
+		}
+
+		bytecode := compiler.Bytecode()
+		testInstructions(a, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(a, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func parse(a *assert.Assertions, input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	for _, msg := range p.Errors() {
+		a.Failf("parser error", msg)
+	}
+	return program
+}
+
+func testInstructions(a *assert.Assertions, expected []code.Instructions, actual code.Instructions) {
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+	a.Equal(concatted.String(), actual.String())
+}
+
+func testConstants(a *assert.Assertions, expected []interface{}, actual []object.Object) {
+	if !a.Equal(len(expected), len(actual)) {
+		return
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if a.True(ok) {
+				a.Equal(int64(constant), integer.Value)
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if a.True(ok) {
+				a.Equal(constant, str.Value)
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if a.True(ok) {
+				testInstructions(a, constant, fn.Instructions)
+			}
+		}
+	}
+}
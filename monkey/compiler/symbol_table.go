@@ -0,0 +1,157 @@
+package compiler
+
+// Where a symbol is stored at runtime.
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// True if the symbol is defined by `const`
+	Const bool
+	// True if the symbol is defined in a block. A global one is captured by closures
+	// like a local because it holds a fresh value in each iteration of a loop.
+	block bool
+}
+
+// A table of symbols in a scope. Tables are chained to the enclosing scope via `Outer`.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	// Symbols captured from enclosing (non-global) scopes
+	FreeSymbols []Symbol
+	// The table of the enclosing function (or the program) if this is a table of a block.
+	// Symbols of a block are only visible in it, but stored in the slots of the function.
+	function *SymbolTable
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s, FreeSymbols: []Symbol{}}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Create a table of a block (e.g. the body of `for-in`) in `outer`.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.function = outer.owner()
+	return s
+}
+
+// Return the table which allocates the slots of the symbols of this table.
+func (s *SymbolTable) owner() *SymbolTable {
+	if s.function != nil {
+		return s.function
+	}
+	return s
+}
+
+// Define a new global or local symbol `name`. Defining it again in the same scope
+// reuses the slot like `let` of the evaluator overwrites the binding.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	owner := s.owner()
+	symbol := Symbol{Name: name, Index: owner.numDefinitions, block: s.function != nil}
+	if owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	owner.numDefinitions += 1
+	return symbol
+}
+
+// Define a new global or local symbol `name` which cannot be reassigned.
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Const = true
+	s.store[name] = symbol
+	return symbol
+}
+
+// Look up the symbol `name` defined in this table, not in the enclosing ones.
+func (s *SymbolTable) lookUpLocal(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	return symbol, ok
+}
+
+// Define a builtin function `name` stored at `index`.
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Define the name of the function which is being compiled.
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope, Const: original.Const}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Look up the symbol `name`. Locals of enclosing functions are turned into free symbols.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.function != nil {
+		// The enclosing scope of a block belongs to the same function
+		return s.Outer.Resolve(name)
+	}
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if symbol.Scope == GlobalScope && !symbol.block || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+
+		return s.defineFree(symbol), true
+	}
+
+	return symbol, ok
+}
+
+// Look up the symbol `name` to be assigned. Unlike `Resolve`, the name of the function
+// being compiled refers to the variable which the function is bound to, like the evaluator.
+func (s *SymbolTable) resolveAssignee(name string) (Symbol, bool) {
+	for t := s; t != nil; t = t.Outer {
+		symbol, ok := t.store[name]
+		if !ok {
+			continue
+		}
+		if symbol.Scope != FunctionScope {
+			break
+		}
+		delete(t.store, name)
+	}
+	return s.Resolve(name)
+}
@@ -0,0 +1,686 @@
+package enginetest
+
+import "monkey/object"
+
+// All of the shared cases
+var Suites = []Suite{
+	{"IntegerArithmetic", []Case{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}},
+	{"BigIntegerPromotion", bigIntegerPromotionCases()},
+	{"BigIntegerComparison", []Case{
+		{"100000000000000000000 > 1", true},
+		{"1 < 100000000000000000000", true},
+		{"-100000000000000000000 < 1", true},
+		{"100000000000000000000 == 100000000000000000000", true},
+		{"100000000000000000000 != 100000000000000000001", true},
+		{"100000000000000000000 == 1e20", true},
+		{"if (100000000000000000000) { 1 } else { 2 }", 1},
+		{"{100000000000000000000: 1}[99999999999999999999 + 1]", 1},
+		{"[1, 2, 3][100000000000000000000]", nil},
+	}},
+	{"Operators", []Case{
+		{"7 % 3", 7 % 3},
+		{"-7 % 3", -7 % 3},
+		{"7 % -3", 7 % -3},
+		{"1 + 10 % 4 * 2", 5},
+		{"7.5 % 2", 1.5},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"2 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 <= 2", true},
+		{"2 >= 2.5", false},
+		{"12 & 10", 12 & 10},
+		{"12 | 10", 12 | 10},
+		{"12 ^ 10", 12 ^ 10},
+		{"-12 & 10", -12 & 10},
+		{"1 << 10", 1 << 10},
+		{"-1024 >> 3", -1024 >> 3},
+		{"1 >> 70", 0},
+		{"1 | 2 ^ 3 & 6", 1 | 2 ^ 3&6},
+		{"1 + 2 << 3", 1 + 2<<3},
+		{"1 < 2 && 2 < 3", true},
+		{"1 < 2 && 3 < 2", false},
+		{"1 > 2 || 2 < 3", true},
+		{"1 > 2 || 3 < 2", false},
+		{"1 && 0", false},
+		{"0 || 5", true},
+		{"false || true && false", false},
+		{"true || false && false", true},
+	}},
+	{"BigIntegerOperators", []Case{
+		{"1 << 64", "18446744073709551616"},
+		{"(1 << 64) >> 63", "2"},
+		{"[1 >> 100000000000000000000, -1 >> 100000000000000000000, (1 << 64) >> 4294967296]", "[0, -1, 0]"},
+		{"[(-1 << 64) >> 64, (-1 << 64) >> 65, -5 >> 18446744073709551616]", "[-1, -1, -1]"},
+		{"3 << 62", "13835058055282163712"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"(1 << 100) % 7", "2"},
+		{"(1 << 64) | 1", "18446744073709551617"},
+		{"((1 << 64) | 255) & 15", "15"},
+		{"(1 << 64) ^ (1 << 64)", "0"},
+		{"(1 << 64) >= (1 << 63)", "true"},
+		{"(1 << 64) <= 5", "false"},
+	}},
+	{"ShortCircuit", []Case{
+		{"let x = 0; let f = fn() { x = x + 1; true }; false && f(); true || f(); x", 0},
+		{"let x = 0; let f = fn() { x = x + 1; true }; true && f(); false || f(); x", 2},
+		{"let x = 0; let f = fn() { x + 1 }; if (false && f()) { 1 } else { 2 }", 2},
+		{"let called = fn() { exit(3) }; true || called(); false && called(); 4", 4},
+		{"false || 1 / 0", Error("division by 0")},
+	}},
+	{"FloatExpressions", []Case{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1e3", 1000.0},
+		{"1.5 + 1.25", 2.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"3 * 0.5", 1.5},
+		{"1 / 4.0", 0.25},
+		{"-2.5 * 2", -5.0},
+		{"10 - 2.5 * 2", 5.0},
+		{"let xs = [1, 2, 4]; (xs[0] + xs[1] + xs[2]) / 3.0", 7.0 / 3},
+	}},
+	{"MixedNumberComparison", []Case{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"2 == 2.0", true},
+		{"2.0 != 2", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"if (0.0) { 1 } else { 2 }", 2},
+		{"!0.5", false},
+	}},
+	{"NumberConversionBuiltins", []Case{
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(7)", 7},
+		{`int(" 42 ")`, 42},
+		{"int(true)", 1},
+		{"float(3)", 3.0},
+		{`float("2.5")`, 2.5},
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"round(2.4)", 2},
+		{"round(5)", 5},
+		{"floor(2.7)", 2},
+		{"floor(-2.1)", -3},
+		{"ceil(2.1)", 3},
+		{`int("abc")`, Error("could not parse \"abc\" as integer")},
+		{`float("x")`, Error("could not parse \"x\" as float")},
+		{"int(1e300 * 1e300)", Error("cannot convert +Inf to INTEGER")},
+		{`round("a")`, Error("argument to `round` must be INTEGER or FLOAT, got STRING")},
+		{"1.0 / 0", Error("division by 0")},
+	}},
+	{"FloatInspect", []Case{
+		{"2.5", "2.5"},
+		{"2.0", "2.0"},
+		{"1e21", "1e+21"},
+		{"float(3)", "3.0"},
+	}},
+	{"BooleanExpressions", []Case{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+	}},
+	{"StructuralEquality", []Case{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2]] == [1, [2]]", true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, [2, [3]]] == [1, [2, [3]]]", true},
+		{"[1, 2.0] == [1.0, 2]", true},
+		{"[] == []", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"{} == {}", true},
+		{"let x = if (false) { 1 }; x == x", true},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"len == len", true},
+		{"len == first", false},
+		{`"1" == 1`, false},
+		{`"1" != 1`, true},
+		{"[1] == 1", false},
+		// Self-referential values
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b", true},
+		{"let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; a == b", false},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = [b]; a == b", true},
+		{`let h = {}; h["h"] = h; let g = {}; g["h"] = g; h == g`, true},
+		{`let h = {}; h["h"] = h; let a = [h]; a == [h]`, true},
+		{`{} == []`, false},
+		{"true == 1", false},
+		{`[1, "a"] == [1, 1]`, false},
+		{"100000000000000000000 == [1]", false},
+	}},
+	{"BangOperator", []Case{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!0", true},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}},
+	{"Conditionals", []Case{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (0) { 10 }", nil},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}},
+	{"ReturnStatements", []Case{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}},
+	{"ErrorHandling", []Case{
+		{"5 + true", Error("unknown operator: INTEGER + BOOLEAN")},
+		{"100000000000000000000 + true", Error("unknown operator: BIG_INTEGER + BOOLEAN")},
+		{"100000000000000000000 / 0", Error("division by 0")},
+		{"5 % 0", Error("modulo by 0")},
+		{"1.5 % 0", Error("modulo by 0")},
+		{"1 << -1", Error("negative shift count: -1")},
+		{"1 << 4294967295", Error("shift count too large: 4294967295 (max 1048576)")},
+		{"1 << 100000000000000000000", Error("shift count too large: 100000000000000000000 (max 1048576)")},
+		{"1.5 & 1", Error("unknown operator: FLOAT & INTEGER")},
+		{"true && 1 + true", Error("unknown operator: INTEGER + BOOLEAN")},
+		{"5 + true; 5;", Error("unknown operator: INTEGER + BOOLEAN")},
+		{"-true", Error("unknown operator: -BOOLEAN")},
+		{"true + false;", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"5; true + false; 5;", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"if (10 > 1) { true + false; }", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"foobar", Error("identifier not found: foobar")},
+		{`"Hello" - "World"`, Error("unknown operator: STRING - STRING")},
+		{"let f = fn() { 1 + true }; f() + 2;", Error("unknown operator: INTEGER + BOOLEAN")},
+		{"5(1)", Error("not a function: INTEGER")},
+	}},
+	{"Throw", []Case{
+		{`throw "oops"; 1`, Error("oops")},
+		{"let f = fn() { throw [1, 2] }; f(); 3", Error("[1, 2]")},
+		{"let f = fn(x) { if (x > 1) { throw x } x }; f(1) + f(2)", Error("2")},
+		{"try { throw 1 } finally { 2 }", Error("1")},
+		{"try { 1 } catch (e) { 2 } finally { throw 3 }", Error("3")},
+	}},
+	{"TryCatch", []Case{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { throw 5; 1 } catch (e) { e * 2 }", 10},
+		{"try { 1 / 0 } catch (e) { 3 }", 3},
+		{`try { 1 / 0 } catch (e) { e["message"] }`, "division by 0"},
+		{`try { 1 + true } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { throw 7 }; try { f() } catch (e) { e }", 7},
+		{"try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e }", 2},
+		{"let x = 0; try { 1 } finally { let x = 5 }; x", 5},
+		{"try { throw 1 } catch (e) { 2 } finally { 3 }", 2},
+		{"let f = fn() { try { return 1 } finally { 2 } }; f()", 1},
+		{"let f = fn() { try { throw 1 } finally { return 2 } }; f()", 2},
+		{"try { if (1 / 0) { 1 } else { 2 } } catch (e) { 3 }", 3},
+		{"let i = 0; while (i < 3) { try { i += 1; continue } finally { i += 10 } }; i", 11},
+		{"let e = 1; try { throw 2 } catch (e) { e }; e", 1},
+		{"let f = fn(x) { try { if (x) { throw x } 0 } catch (e) { e + 1 } finally { x } }; f(1) + f(false)", 2},
+		{"let f = fn() { try { try { return 1 } finally { throw 2 } } catch (e) { e } }; f()", 2},
+		{"try { exit(2) } catch (e) { 1 }", Exit(2)},
+	}},
+	{"LetStatements", []Case{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5;", nil},
+	}},
+	{"AssignExpressions", []Case{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x = 5", 5},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let x = 10; x += 2; x", 12},
+		{"let x = 10; x -= 2; x", 8},
+		{"let x = 10; x *= 2; x", 20},
+		{"let x = 10; x /= 2; x", 5},
+		{"let count = 0; let inc = fn() { count += 1 }; inc(); inc(); count", 2},
+		// Captured variables are shared by the closures and the scope which defines them
+		{"let mk = fn() { let c = 0; fn() { c += 1; c } }; let g = mk(); g(); g()", 2},
+		{"let mk = fn() { let c = 0; fn() { c += 1 } }; let g = mk(); let h = mk(); g(); g(); h()", 1},
+		{"let mk = fn() { let c = 0; [fn() { c += 1 }, fn() { c }] }; let p = mk(); p[0](); p[0](); p[1]()", 2},
+		{"let f = fn(n) { let inc = fn() { n += 1 }; inc(); n }; f(1)", 2},
+		{"let f = fn() { let c = 0; let g = fn() { fn() { c += 1 } }; g()(); g()(); c }; f()", 2},
+		{"let f = fn() { f = 1; 2 }; f() + f", 3},
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"let f = fn(n) { n *= 2; n += 1; n }; f(3)", 7},
+		{"let arr = [1, 2, 3]; arr[1] = 5; arr[1]", 5},
+		{"let arr = [1, 2, 3]; arr[2] *= 4; arr[2]", 12},
+		{`let h = {"k": 1}; h["k"] = 7; h["k"]`, 7},
+		{`let h = {}; h["n"] = 3; h["n"] += 1; h["n"]`, 4},
+		{"let arr = [[1]]; arr[0][0] = 2; arr", "[[2]]"},
+		{"const c = 3; c + 1", 4},
+		{"const c = 3; let f = fn() { let c = 4; c = 5; c }; f()", 5},
+	}},
+	{"AssignErrors", []Case{
+		{"x = 1", Error("identifier not found: x")},
+		{"len = 1", Error("identifier not found: len")},
+		{"const c = 1; c = 2", Error("cannot assign to constant: c")},
+		{"const c = 1; c += 2", Error("cannot assign to constant: c")},
+		{"const c = 1; let c = 2", Error("cannot assign to constant: c")},
+		{"const c = 1; let f = fn() { c = 2 }; f()", Error("cannot assign to constant: c")},
+		{"let arr = [1]; arr[1] = 2", Error("index out of range: 1")},
+		{`let arr = [1]; arr["a"] = 2`, Error("array index must be INTEGER, got STRING")},
+		{"let arr = [1]; arr[5] += 2", Error("unknown operator: NULL + INTEGER")},
+		{`let s = "abc"; s[0] = "x"`, Error("index assignment not supported: STRING")},
+		{"let a = [1]; a[0] = a; let h = {}; h[a] = 1", Error("unusable as hash key: ARRAY")},
+		{"let a = [1]; a[0] = a; {a: 1}", Error("unusable as hash key: ARRAY")},
+		{"let a = [1]; a[0] = a; has({}, a)", Error("argument 2 to `has` must be hashable, got ARRAY")},
+		{`let x = "a"; x -= 1`, Error("unknown operator: STRING - INTEGER")},
+	}},
+	{"SelfReferentialValues", []Case{
+		{"let a = [1, 2]; a[0] = a; a", "[[...], 2]"},
+		{`let h = {"x": 1}; h["self"] = h; h`, "{x: 1, self: {...}}"},
+		{`let a = [1]; let h = {"a": a}; a[0] = h; a`, "[{a: [...]}]"},
+		{"let a = [1]; a[0] = a; str(a)", "[[...]]"},
+		{`let a = [1]; a[0] = a; "${a}"`, "[[...]]"},
+		// Shared (not cyclic) values are printed in full
+		{"let a = [1]; [a, a]", "[[1], [1]]"},
+		{"let a = [1]; a[0] = a; flatten(a)", "[[[...]]]"},
+	}},
+	{"Loops", []Case{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; while (i < 10) { i += 1 }", nil},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break } }; i", 5},
+		{"let i = 0; let s = 0; while (i < 10) { i += 1; if (i > 3) { continue } s += i }; s", 6},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x }; s", 6},
+		{"for (x in [1, 2, 3]) { x }", nil},
+		{`let n = 0; for (c in "日本語") { n += 1 }; n`, 3},
+		{`let s = 0; for (k in {1: "a", 2: "b"}) { s += k }; s`, 3},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break } s += x }; s", 3},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue } s += x }; s", 4},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10 } } }; f()", 20},
+		{"let f = fn() { for (x in [1, 2, 3]) { x } }; f()", nil},
+		{"let f = fn(xs) { let s = 0; for (x in xs) { s += x }; s }; f([1, 2]) + f([3])", 6},
+		{"let s = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y == 20) { break } s += x * y } }; s", 30},
+		{"let x = 10; for (x in [1, 2]) { x }; x", 10},
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; map(fs, fn(f) { f() })", "[1, 2]"},
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; fs[0]() + fs[1]() * 10", 21},
+		{"let f = fn() { let fs = []; for (x in [1, 2]) { let y = x; fs = push(fs, fn() { y }) }; fs[0]() }; f()", 1},
+		{"let fs = []; for (x in [1, 2]) { try { throw x } catch (e) { fs = push(fs, fn() { e }) } }; fs[0]() + fs[1]() * 10", 21},
+		{"let fs = []; let i = 0; while (i < 2) { i += 1; let y = i; fs = push(fs, fn() { y }) }; fs[0]()", 2},
+		{"let i = 0; while (i < 5000) { i += 1; try { throw i } finally { continue } }; i", 5000},
+		{"let i = 0; while (i < 1000000) { i += 1 }; i", 1000000},
+		{"for (x in 5) { x }", Error("not iterable: INTEGER")},
+	}},
+	{"BuiltinFunctions", []Case{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hwllo world")`, 11},
+		{`len("日本")`, 2},
+		{`len(1)`, Error("argument to `len` not supported, got INTEGER")},
+		{`len("one", "two")`, Error("wrong number of arguments. got=2, want=1")},
+		{`len([3, 3, 4])`, 3},
+		{`first([3, 3, 4])`, 3},
+		{`last([3, 3, 4])`, 4},
+		{`rest([3, 3, 4])`, "[3, 4]"},
+		{`push([3, 3, 4], 5)`, "[3, 3, 4, 5]"},
+	}},
+	{"StringLiterals", []Case{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"tab\there\n"`, "tab\there\n"},
+		{`"\"quoted\" \\ \u{2764}"`, "\"quoted\" \\ ❤"},
+		{"`C:\\path\\no\\escape`", "C:\\path\\no\\escape"},
+		{"`multi\nline` + \"!\"", "multi\nline!"},
+	}},
+	{"StringInterpolation", []Case{
+		{`let xs = [1, 2, 3]; "total: ${len(xs)} items"`, "total: 3 items"},
+		{`let x = 2; "${x} * ${x} = ${x * x}"`, "2 * 2 = 4"},
+		{`let null_value = fn() { if (false) { 1 } }; "${[1, "a", true]} ${null_value()}"`, "[1, a, true] null"},
+		{`let h = {"k": "v"}; "value: ${h["k"]}!"`, "value: v!"},
+		{`let name = "x"; "outer ${"inner ${name}"}"`, "outer inner x"},
+		{`"${1.5}${2}"`, "1.52"},
+		{`"cost: \${price}"`, "cost: ${price}"},
+		{`str(42) + str([1]) + str("s")`, "42[1]s"},
+		{`let f = fn(x) { "x=${x}, double=${x * 2}" }; f(21) + " ${[1, 2]}"`, "x=21, double=42 [1, 2]"},
+	}},
+	{"StringOperations", []Case{
+		{`"héllo"[1]`, "é"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+		{`"日本語"[1:]`, "本語"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[3:1]`, ""},
+		{`"hello"[-5:100]`, "hello"},
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3][:]`, "[1, 2, 3]"},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"abc" <= "abc"`, true},
+		{`"abc" >= "abd"`, false},
+		{`"abc" == "abc"`, true},
+		{`"abc" != "abc"`, false},
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`split("  a b\tc ")`, "[a, b, c]"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join(["a", "b"])`, "ab"},
+		{`trim("  hi \n")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`contains("seafood", "foo")`, true},
+		{`contains("seafood", "bar")`, false},
+		{`starts_with("golang", "go")`, true},
+		{`ends_with("golang", "go")`, false},
+		{`upper("abc")`, "ABC"},
+		{`lower("ÀBC")`, "àbc"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`repeat("", 100000000000000000000)`, ""},
+		{`index_of("日本語", "語")`, 2},
+		{`index_of("abc", "z")`, -1},
+		{`format("%s has %d items (%.1f%%)", "cart", 3, 12.345)`, "cart has 3 items (12.3%)"},
+		{`format("%v %v %5d|", [1, 2], true, 42)`, "[1, 2] true    42|"},
+		{`format("%d", 100000000000000000000)`, "100000000000000000000"},
+		{`format("%s|%s|%.2f|%x|%q|%t|%5.1f%%", 1.5, [1], 2, 255, "a", false, 9.87)`, `1.5|[1]|2.00|ff|"a"|false|  9.9%`},
+		{`let s = "日本語"; [s[1], s[1:], s[:1], [1, 2, 3][1:], "a" < "b", upper("x"), len(s)]`, "[本, 本語, 日, [2, 3], true, X, 3]"},
+	}},
+	{"StringErrors", []Case{
+		{`"a" - "b"`, Error("unknown operator: STRING - STRING")},
+		{`"abc"["a"]`, Error("index operator not supported: STRING")},
+		{`"abc"["a":]`, Error("slice bound must be INTEGER, got STRING")},
+		{`1[0:1]`, Error("slice operator not supported: INTEGER")},
+		{`split(1)`, Error("argument 1 to `split` must be STRING, got INTEGER")},
+		{`split("a", "b", "c")`, Error("wrong number of arguments. got=3, want=1..2")},
+		{`join([1, 2], ",")`, Error("elements of argument to `join` must be STRING, got INTEGER")},
+		{`replace("a", "b")`, Error("wrong number of arguments. got=2, want=3")},
+		{`repeat("a", -1)`, Error("negative repeat count: -1")},
+		{`repeat("a", 100000000000000000000)`, Error("repeat count too large: 100000000000000000000")},
+		{`repeat("a", -100000000000000000000)`, Error("negative repeat count: -100000000000000000000")},
+		{`format()`, Error("wrong number of arguments. got=0, want>=1")},
+		{`format(1)`, Error("argument 1 to `format` must be STRING, got INTEGER")},
+		{`format("%d", 1.5)`, Error("argument 2 to `format` for %d must be INTEGER or BIG_INTEGER, got FLOAT")},
+		{`format("%s %t", "a", 1)`, Error("argument 3 to `format` for %t must be BOOLEAN, got INTEGER")},
+		{`format("%s")`, Error("missing argument for %s in the format string")},
+		{`format("%d", 1, 2)`, Error("too many arguments to `format`: got=2, want=1")},
+		{`format("%y", 1)`, Error("unknown verb in the format string: %y")},
+		{`format("100%")`, Error("incomplete verb at the end of the format string")},
+	}},
+	{"ArrayBuiltins", []Case{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map([1, -2], str)`, "[1, -2]"},
+		{`let k = 10; map([1, 2], fn(x) { x + k })`, "[11, 12]"},
+		{`filter(range(10), fn(x) { x % 3 == 0 })`, "[0, 3, 6, 9]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, "10"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + str(x) }, "")`, "123"},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, "0"},
+		{`let sum = 0; each([1, 2, 3], fn(x) { sum += x }); sum`, "6"},
+		{`find([1, 4, 9], fn(x) { x > 3 })`, "4"},
+		{`find([1, 4, 9], fn(x) { x > 10 })`, "null"},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([1, 2, 3], fn(x) { x > 3 })`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`[any([]), all([]), any([0, 1]), all([1, 0])]`, "[false, true, true, false]"},
+		{`sort([3, 1.5, 2, -1])`, "[-1, 1.5, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort(["bb", "a", "ccc"], fn(a, b) { len(a) - len(b) })`, "[a, bb, ccc]"},
+		{`let xs = [2, 1]; sort(xs); xs`, "[2, 1]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`reverse("日本語")`, "語本日"},
+		{`concat([1], [], [2, 3])`, "[1, 2, 3]"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4], 2)`, "[3, 4]"},
+		{`slice("hello", 1, 3)`, "el"},
+		{`flatten([1, [2, [3, [4]]]])`, "[1, 2, [3, [4]]]"},
+		{`flatten([1, [2, [3, [4]]]], 10)`, "[1, 2, 3, 4]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip()`, "[]"},
+		{`range(4)`, "[0, 1, 2, 3]"},
+		{`range(2, 5)`, "[2, 3, 4]"},
+		{`range(5, 0, -2)`, "[5, 3, 1]"},
+		{`range(-1)`, "[]"},
+		{`range(9223372036854775800, 9223372036854775807, 10)`, "[9223372036854775800]"},
+		{`range(-9223372036854775800, -9223372036854775807 - 1, -7)`, "[-9223372036854775800, -9223372036854775807]"},
+		{`range(0, 7, 3)`, "[0, 3, 6]"},
+		{`range(9223372036854775806, 9223372036854775809)`, "[9223372036854775806, 9223372036854775807, 9223372036854775808]"},
+		{`[range(0, 10, 100000000000000000000), range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775808)]`, "[[0], [-9223372036854775808, 0]]"},
+		{`[range(100000000000000000000, 100000000000000000002), range(-100000000000000000000)]`, "[[100000000000000000000, 100000000000000000001], []]"},
+		{`[flatten([1, [2, [3]]], 100000000000000000000), flatten([1, [2]], -100000000000000000000)]`, "[[1, 2, 3], [1, [2]]]"},
+		{
+			`let double = fn(x) { x * 2 };
+			let k = 3;
+			[
+				map([1, 2, 3], double),
+				filter(range(6), fn(x) { x % k == 0 }),
+				reduce(map([1, 2, 3], fn(x) { double(x) + k }), fn(acc, x) { acc + x }, 0),
+				sort([3, 1, 2], fn(a, b) { a > b }),
+				map([[1, 2], [3]], fn(xs) { reduce(xs, fn(acc, x) { acc + x }) }),
+				find([1, 2, 3], fn(x) { x > 1 }),
+				all([2, 4], fn(x) { x % 2 == 0 })
+			]`,
+			"[[2, 4, 6], [0, 3], 21, [3, 2, 1], [3, 3], 2, true]",
+		},
+	}},
+	{"ArrayBuiltinErrors", []Case{
+		{`map([1], 1)`, Error("argument 2 to `map` must be a function, got INTEGER")},
+		{`map(1, fn(x) { x })`, Error("argument 1 to `map` must be ARRAY, got INTEGER")},
+		{`map([1, "a"], fn(x) { x + 1 })`, Error("unknown operator: STRING + INTEGER")},
+		{`map([1, "a"], fn(x) { x + 1 }); 99`, Error("unknown operator: STRING + INTEGER")},
+		{`map([1], fn(x) { exit(3) }); 99`, Exit(3)},
+		{`map([1], fn(x, y) { x }); 99`, Error("wrong number of arguments. got=1, want=2")},
+		{`reduce([], fn(acc, x) { acc + x })`, Error("reduce of empty array with no initial value")},
+		{`sort([1, "a"])`, Error("unknown operator: STRING < INTEGER")},
+		{`sort([1, 2], fn(a, b) { "x" })`, Error("comparator of `sort` must return BOOLEAN or INTEGER, got STRING")},
+		{`reverse(1)`, Error("argument to `reverse` must be ARRAY or STRING, got INTEGER")},
+		{`concat([1], 2)`, Error("argument 2 to `concat` must be ARRAY, got INTEGER")},
+		{`zip([1], "a")`, Error("argument 2 to `zip` must be ARRAY, got STRING")},
+		{`let a = [1]; a[0] = a; flatten(a, 100)`, Error("cannot flatten a cyclic array")},
+		{`range(0, 10, 0)`, Error("step of `range` must not be 0")},
+		{`range(0, 300000000)`, Error("result of `range` is too long: 300000000 elements (max 16777216)")},
+		{`range(-9223372036854775807 - 1, 9223372036854775807)`, Error("result of `range` is too long: 18446744073709551615 elements (max 16777216)")},
+		{`range("a")`, Error("argument 1 to `range` must be INTEGER, got STRING")},
+		{`range(100000000000000000000)`, Error("result of `range` is too long: 100000000000000000000 elements (max 16777216)")},
+		{`each([1], fn(x) { throw "stop" })`, Error("stop")},
+	}},
+	{"HashLiterals", []Case{
+		{
+			`let two = "two";
+			{
+				"one": 10 - 9,
+				two: 1 + 1,
+				"thr" + "ee": 6 / 2,
+				4: 4,
+				true: 5,
+				false: 6
+			}`,
+			"{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}",
+		},
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+	}},
+	{"HashBuiltins", []Case{
+		{`let h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, "{b: 4, a: 2, c: 3}"},
+		{`keys({"z": 1, "y": 2, "x": 3})`, "[z, y, x]"},
+		{`values({"z": 1, "y": 2, "x": 3})`, "[1, 2, 3]"},
+		{`items({"a": 1, 2: "b"})`, "[[a, 1], [2, b]]"},
+		{`let r = []; for (k in {"z": 1, "a": 2}) { r = push(r, k) }; r`, "[z, a]"},
+		{`[has({"a": 1}, "a"), has({"a": 1}, "b")]`, "[true, false]"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; [delete(h, "b"), delete(h, "x"), h]`, "[2, null, {a: 1, c: 3}]"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); h["a"] = 3; h`, "{b: 2, a: 3}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`let h = {"a": 1}; merge(h, {"b": 2}); h`, "{a: 1}"},
+		{`merge()`, "{}"},
+		{`[len({}), len({"a": 1, "b": 2})]`, "[0, 2]"},
+		{
+			`let h = merge({"b": 1, "a": 2}, {"c": 3});
+			[h, keys(h), values(h), has(h, "a"), delete(h, "b"), merge(h, {"a": 4}), len(h)]`,
+			"[{a: 2, c: 3}, [b, a, c], [1, 2, 3], true, 1, {a: 4, c: 3}, 2]",
+		},
+	}},
+	{"HashBuiltinErrors", []Case{
+		{`keys([1])`, Error("argument 1 to `keys` must be HASH, got ARRAY")},
+		{`values({}, {})`, Error("wrong number of arguments. got=2, want=1")},
+		{`has({}, [len])`, Error("argument 2 to `has` must be hashable, got ARRAY")},
+		{`merge({}, 1)`, Error("argument 2 to `merge` must be HASH, got INTEGER")},
+	}},
+	{"CompositeHashKeys", []Case{
+		{`let memo = {}; memo[[1, 2]] = "a"; memo[[1, 2]]`, "a"},
+		{`let memo = {}; let x = 1; memo[[x, 2]] = "a"; [memo[[1, 2]], memo[[2, 1]]]`, "[a, null]"},
+		{`{[1, [2]]: 1, [1, [2]]: 2}`, "{[1, [2]]: 2}"},
+		{`let h = {{"a": 1, "b": 2}: "x"}; h[{"b": 2, "a": 1}]`, "x"},
+		{`let h = {[1]: 1, [1, 2]: 2}; [has(h, [1]), has(h, [2]), len(h)]`, "[true, false, 2]"},
+		{`let k = [1]; let h = {k: 1}; k[0] = 2; [h, h[[1]], h[[2]], k]`, "[{[1]: 1}, 1, null, [2]]"},
+		{`let h = {}; h[[]] = 1; h[{}] = 2; h`, "{[]: 1, {}: 2}"},
+		{`let h = {[1, 2]: "a", {"x": [3]}: "b"}; [h[[1, 2]], h[{"x": [3]}], h[[2, 1]], keys(h)]`, "[a, b, null, [[1, 2], {x: [3]}]]"},
+	}},
+	{"NumericHashKeys", []Case{
+		{`{2: "a"}[2.0]`, "a"},
+		{`{2: 1} == {2.0: 1}`, true},
+		{`let h = {2: "a"}; h[2.0] = "b"; h`, "{2: b}"},
+		{`[{1.5: "a"}[1.5], {2.5: "a"}[2], has({0: 1}, -0.0)]`, "[a, null, true]"},
+		{`{100000000000000000000: "a"}[float(100000000000000000000)]`, "a"},
+		{`{[1, 2]: "a"}[[1.0, 2.0]]`, "a"},
+	}},
+	{"FrozenHashKeys", []Case{
+		{`let h = {[1]: 1}; let k = keys(h)[0]; k[0] = 2`, Error("cannot modify frozen ARRAY")},
+		{`let h = {{"a": 1}: 1}; let k = keys(h)[0]; k["a"] = 2`, Error("cannot modify frozen HASH")},
+		{`let h = {{"a": 1}: 1}; delete(keys(h)[0], "a")`, Error("cannot modify frozen HASH")},
+		{`{[1, fn(x) { x }]: 1}`, Error("unusable as hash key: ARRAY")},
+		{`{"a": 1}[{"f": len}]`, Error("unusable as hash key: HASH")},
+	}},
+	{"ArrayLiterals", []Case{
+		{"[]", "[]"},
+		{"[1, 2 + 2, 3 * 3]", "[1, 4, 9]"},
+	}},
+	{"IndexExpressions", []Case{
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+	}},
+	{"FunctionApplication", []Case{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) {x; }(5)", 5},
+		{"fn() { }()", nil},
+		{"map([1], fn(x) { })", "[null]"},
+		{"let f = fn(a) { let b = a * 2; let c = b + 1; c }; f(3) + f(4);", 16},
+	}},
+	{"Closures", []Case{
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3);", 5},
+		{"let newAdder = fn(a, b) { fn(c) { fn(d) { a + b + c + d } } }; newAdder(1, 2)(3)(4);", 10},
+		{"let fib = fn(x) { if (x < 2) { return x; } fib(x - 1) + fib(x - 2) }; fib(15);", 610},
+		{
+			`let wrapper = fn() {
+				let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1); };
+				countDown(1);
+			};
+			wrapper();`,
+			0,
+		},
+	}},
+	{"Parameters", []Case{
+		{"let f = fn(a, b = 10) { a + b }; [f(1), f(1, 2)]", "[11, 3]"},
+		{"let f = fn(a, b = a * 2, c = a + b) { [a, b, c] }; [f(1), f(1, 5), f(1, 5, 0)]", "[[1, 2, 3], [1, 5, 6], [1, 5, 0]]"},
+		{"let a = 100; let f = fn(a = a) { a }; [f(), f(1)]", "[100, 1]"},
+		{"let f = fn() { [] }; let g = fn(xs = f()) { xs }; g() == g()", "true"},
+		{"let f = fn(first, ...rest) { [first, rest] }; [f(1), f(1, 2, 3)]", "[[1, []], [1, [2, 3]]]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; [f(1), f(1, 3, 4, 5)]", "[[1, 2, []], [1, 3, [4, 5]]]"},
+		{"let sum = fn(...xs) { reduce(xs, fn(acc, x) { acc + x }, 0) }; [sum(), sum(1, 2, 3)]", "[0, 6]"},
+		{"let f = fn(a, b, c) { [a, b, c] }; let xs = [2, 3]; f(1, ...xs)", "[1, 2, 3]"},
+		{"let f = fn(...args) { args }; f(...[1], 2, ...[], ...[3, 4])", "[1, 2, 3, 4]"},
+		{"let f = fn(a, b = 0) { a - b }; map([[5, 2], [5]], fn(pair) { f(...pair) })", "[3, 5]"},
+		{"len(...[[1, 2]])", "2"},
+		{"let outer = fn(x) { fn(y = x, ...zs) { [y, zs] } }; [outer(1)(), outer(1)(2, 3)]", "[[1, []], [2, [3]]]"},
+	}},
+	{"ArityErrors", []Case{
+		{"fn(a, b) { a + b }(1)", Error("wrong number of arguments. got=1, want=2")},
+		{"fn(a) { a }(1, 2)", Error("wrong number of arguments. got=2, want=1")},
+		{"fn(a, b = 1) { a }()", Error("wrong number of arguments. got=0, want=1..2")},
+		{"fn(a, b = 1) { a }(1, 2, 3)", Error("wrong number of arguments. got=3, want=1..2")},
+		{"fn(a, ...rest) { a }()", Error("wrong number of arguments. got=0, want>=1")},
+		{"fn(a, b) { a }(...[1, 2, 3])", Error("wrong number of arguments. got=3, want=2")},
+		{"fn(a) { a }(...1)", Error("spread argument must be ARRAY, got INTEGER")},
+		{"fn(a = 1 + true) { a }()", Error("unknown operator: INTEGER + BOOLEAN")},
+	}},
+	{"Exit", []Case{
+		{"exit(0); 334;", Exit(0)},
+		{"264; exit(0); 334;", Exit(0)},
+		{"exit(227); 334;", Exit(227)},
+		{"let f = fn() { exit(3); 4 }; f(); 5;", Exit(3)},
+	}},
+}
+
+func bigIntegerPromotionCases() []Case {
+	factorial := "let factorial = fn(n) { if (n < 2) { 1 } else { n * factorial(n - 1) } };"
+	return []Case{
+		{factorial + "factorial(20)", Value{object.INTEGER, "2432902008176640000"}},
+		{factorial + "factorial(25)", Value{object.BIG_INTEGER, "15511210043330985984000000"}},
+		{factorial + "factorial(25) / factorial(24)", Value{object.INTEGER, "25"}},
+		{"9223372036854775807 + 1", Value{object.BIG_INTEGER, "9223372036854775808"}},
+		{"9223372036854775807 + 1 - 1", Value{object.INTEGER, "9223372036854775807"}},
+		{"-9223372036854775807 - 2", Value{object.BIG_INTEGER, "-9223372036854775809"}},
+		{"-9223372036854775807 - 1", Value{object.INTEGER, "-9223372036854775808"}},
+		{"-(-9223372036854775807 - 1)", Value{object.BIG_INTEGER, "9223372036854775808"}},
+		{"(-9223372036854775807 - 1) / -1", Value{object.BIG_INTEGER, "9223372036854775808"}},
+		{"4294967296 * 4294967296", Value{object.BIG_INTEGER, "18446744073709551616"}},
+		{"-4294967296 * 4294967296", Value{object.BIG_INTEGER, "-18446744073709551616"}},
+		{"100000000000000000000", Value{object.BIG_INTEGER, "100000000000000000000"}},
+		{"100000000000000000000 - 99999999999999999999", Value{object.INTEGER, "1"}},
+		{"-100000000000000000000 / 3", Value{object.BIG_INTEGER, "-33333333333333333333"}},
+		{`int("123456789012345678901234567890")`, Value{object.BIG_INTEGER, "123456789012345678901234567890"}},
+		{"int(1e20)", Value{object.BIG_INTEGER, "100000000000000000000"}},
+		{"floor(100000000000000000000)", Value{object.BIG_INTEGER, "100000000000000000000"}},
+		{"float(100000000000000000000)", Value{object.FLOAT, "1e+20"}},
+		{"100000000000000000000 + 0.5", Value{object.FLOAT, "1e+20"}},
+		{factorial + "[factorial(25), factorial(25) / factorial(24), -(-9223372036854775807 - 1), 100000000000000000000 > 1]", "[15511210043330985984000000, 25, 9223372036854775808, true]"},
+	}
+}
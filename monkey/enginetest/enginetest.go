@@ -0,0 +1,108 @@
+// Package enginetest provides test cases shared by the execution engines (`evaluator` and `vm`)
+// so that both of them are checked against the same expectations.
+package enginetest
+
+import (
+	"testing"
+
+	"monkey/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A program and its expected result. `Expected` is one of:
+//
+//   - int, float64, bool or nil for INTEGER, FLOAT, BOOLEAN or NULL
+//   - string compared with the representation (`Inspect`) of the result
+//   - `Value` compared with the kind and the representation of the result
+//   - `Error` or `Exit`
+type Case struct {
+	Input    string
+	Expected interface{}
+}
+
+// An expected result of a kind, e.g. to tell BIG_INTEGER from INTEGER.
+type Value struct {
+	Kind    object.ObjectKind
+	Inspect string
+}
+
+// An expected error with the message. An engine may report it before running the program
+// (e.g. an undefined identifier found by the compiler).
+type Error string
+
+// An expected `exit` with the status.
+type Exit int
+
+// A named group of cases.
+type Suite struct {
+	Name  string
+	Cases []Case
+}
+
+// Run a program by an engine. The error is a failure which prevents running it.
+type Engine func(input string) (object.Object, error)
+
+// Run all of `Suites` by `engine` as subtests.
+func Run(t *testing.T, engine Engine) {
+	for _, suite := range Suites {
+		suite := suite
+		t.Run(suite.Name, func(t *testing.T) {
+			a := assert.New(t)
+			for _, tc := range suite.Cases {
+				result, err := engine(tc.Input)
+				check(a, tc, result, err)
+			}
+		})
+	}
+}
+
+func check(a *assert.Assertions, tc Case, result object.Object, err error) {
+	if err != nil {
+		expected, ok := tc.Expected.(Error)
+		if a.True(ok, "%s: unexpected error: %s", tc.Input, err) {
+			a.Equal(string(expected), err.Error(), tc.Input)
+		}
+		return
+	}
+	if !a.NotNil(result, tc.Input) {
+		return
+	}
+
+	switch expected := tc.Expected.(type) {
+	case int:
+		integer, ok := result.(*object.Integer)
+		if a.True(ok, "%s: got %s", tc.Input, result.Inspect()) {
+			a.Equal(int64(expected), integer.Value, tc.Input)
+		}
+	case float64:
+		float, ok := result.(*object.Float)
+		if a.True(ok, "%s: got %s", tc.Input, result.Inspect()) {
+			a.InDelta(expected, float.Value, 1e-9, tc.Input)
+		}
+	case bool:
+		boolean, ok := result.(*object.Boolean)
+		if a.True(ok, "%s: got %s", tc.Input, result.Inspect()) {
+			a.Equal(expected, boolean.Value, tc.Input)
+		}
+	case nil:
+		a.Equal(object.NULL, result.Kind(), "%s: got %s", tc.Input, result.Inspect())
+	case string:
+		a.Equal(expected, result.Inspect(), tc.Input)
+	case Value:
+		a.Equal(expected.Kind, result.Kind(), tc.Input)
+		a.Equal(expected.Inspect, result.Inspect(), tc.Input)
+	case Error:
+		errObj, ok := result.(*object.Error)
+		if a.True(ok, "%s: got %s", tc.Input, result.Inspect()) {
+			a.Equal(string(expected), errObj.Message, tc.Input)
+		}
+	case Exit:
+		exit, ok := result.(*object.Exit)
+		if a.True(ok, "%s: got %s", tc.Input, result.Inspect()) {
+			a.Equal(int(expected), exit.Status, tc.Input)
+		}
+	default:
+		a.Failf("unknown expectation", "%s: %T", tc.Input, tc.Expected)
+	}
+}
@@ -0,0 +1,432 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/object"
+	"sort"
+)
+
+var arrayBuiltins = map[string]*object.Builtin{
+	"map": &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("map", args, 2, 2, object.ARRAY); err != nil {
+				return err
+			}
+			if err := checkCallable("map", args, 1); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			result := make([]object.Object, len(elements))
+			for i, el := range elements {
+				mapped := call(args[1], el)
+				if isErrorOrExit(mapped) {
+					return mapped
+				}
+				result[i] = mapped
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"filter": &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("filter", args, 2, 2, object.ARRAY); err != nil {
+				return err
+			}
+			if err := checkCallable("filter", args, 1); err != nil {
+				return err
+			}
+
+			result := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				ok := call(args[1], el)
+				if isErrorOrExit(ok) {
+					return ok
+				}
+				if isTruthy(ok) {
+					result = append(result, el)
+				}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"reduce": &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("reduce", args, 2, 3, object.ARRAY); err != nil {
+				return err
+			}
+			if err := checkCallable("reduce", args, 1); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else if len(elements) > 0 {
+				// Start from the first element without the initial value
+				acc, elements = elements[0], elements[1:]
+			} else {
+				return newError(object.VALUE_ERROR, "reduce of empty array with no initial value")
+			}
+
+			for _, el := range elements {
+				acc = call(args[1], acc, el)
+				if isErrorOrExit(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+	"each": &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("each", args, 2, 2, object.ARRAY); err != nil {
+				return err
+			}
+			if err := checkCallable("each", args, 1); err != nil {
+				return err
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				if result := call(args[1], el); isErrorOrExit(result) {
+					return result
+				}
+			}
+			return NULL
+		},
+	},
+	"find": &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("find", args, 2, 2, object.ARRAY); err != nil {
+				return err
+			}
+			if err := checkCallable("find", args, 1); err != nil {
+				return err
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				ok := call(args[1], el)
+				if isErrorOrExit(ok) {
+					return ok
+				}
+				if isTruthy(ok) {
+					return el
+				}
+			}
+			return NULL
+		},
+	},
+	"any": quantifier("any", true),
+	"all": quantifier("all", false),
+	"sort": &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("sort", args, 1, 2, object.ARRAY); err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				if err := checkCallable("sort", args, 1); err != nil {
+					return err
+				}
+			}
+
+			elements := args[0].(*object.Array).Elements
+			sorted := make([]object.Object, len(elements))
+			copy(sorted, elements)
+
+			// The first error stops the comparisons
+			var err object.Object
+			less := func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				var result object.Object
+				if len(args) == 2 {
+					result = call(args[1], sorted[i], sorted[j])
+				} else {
+					result = evalInfixExpression("<", sorted[i], sorted[j])
+				}
+
+				switch result := result.(type) {
+				case *object.Boolean:
+					return result.Value
+				case *object.Integer:
+					// A negative value means the first argument comes first
+					return result.Value < 0
+				case *object.Error, *object.Exit:
+					err = result
+				default:
+					err = newError(object.TYPE_ERROR, "comparator of `sort` must return BOOLEAN or INTEGER, got %s", result.Kind())
+				}
+				return false
+			}
+			sort.SliceStable(sorted, less)
+
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: sorted}
+		},
+	},
+	"reverse": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("reverse", args, 1, 1); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				length := len(arg.Elements)
+				reversed := make([]object.Object, length)
+				for i, el := range arg.Elements {
+					reversed[length-1-i] = el
+				}
+				return &object.Array{Elements: reversed}
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			default:
+				return newError(object.TYPE_ERROR, "argument to `reverse` must be ARRAY or STRING, got %s", arg.Kind())
+			}
+		},
+	},
+	"concat": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			length := int64(0)
+			for i, arg := range args {
+				array, ok := arg.(*object.Array)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to `concat` must be ARRAY, got %s", i+1, arg.Kind())
+				}
+				length += int64(len(array.Elements))
+			}
+			if err := reserve(limits, sizeOf(length, elementSize)); err != nil {
+				return err
+			}
+
+			result := make([]object.Object, 0, length)
+			for _, arg := range args {
+				result = append(result, arg.(*object.Array).Elements...)
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"slice": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("slice", args, 2, 3); err != nil {
+				return err
+			}
+
+			end := object.Object(NULL)
+			if len(args) == 3 {
+				end = args[2]
+			}
+			return evalSlice(args[0], args[1], end)
+		},
+	},
+	"flatten": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			if err := checkArgs("flatten", args, 1, 2, object.ARRAY, object.INTEGER); err != nil {
+				return err
+			}
+
+			depth := int64(1)
+			if len(args) == 2 {
+				// A BIG_INTEGER depth flattens as deep as `math.MaxInt64`, i.e. completely
+				depth = clampInteger(args[1])
+			}
+			array := args[0].(*object.Array)
+			elements, err := flatten(limits, array.Elements, depth, map[*object.Array]bool{})
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"zip": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return &object.Array{Elements: []object.Object{}}
+			}
+
+			arrays := make([]*object.Array, len(args))
+			length := -1
+			for i, arg := range args {
+				array, ok := arg.(*object.Array)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to `zip` must be ARRAY, got %s", i+1, arg.Kind())
+				}
+				arrays[i] = array
+				// The result is as long as the shortest array
+				if length < 0 || len(array.Elements) < length {
+					length = len(array.Elements)
+				}
+			}
+
+			// The result and its tuples
+			if err := reserve(limits, sizeOf(int64(length), int64(len(arrays)+1)*elementSize)); err != nil {
+				return err
+			}
+
+			result := make([]object.Object, length)
+			for i := range result {
+				if err := poll(limits, i); err != nil {
+					return err
+				}
+				tuple := make([]object.Object, len(arrays))
+				for j, array := range arrays {
+					tuple[j] = array.Elements[i]
+				}
+				result[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"range": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			if err := checkArgs("range", args, 1, 3, object.INTEGER, object.INTEGER, object.INTEGER); err != nil {
+				return err
+			}
+
+			// range(end), range(start, end) or range(start, end, step)
+			start, end, step := big.NewInt(0), toBigInt(args[0]), big.NewInt(1)
+			if len(args) >= 2 {
+				start, end = end, toBigInt(args[1])
+			}
+			if len(args) == 3 {
+				step = toBigInt(args[2])
+			}
+			if step.Sign() == 0 {
+				return newError(object.VALUE_ERROR, "step of `range` must not be 0")
+			}
+
+			length := rangeLength(start, end, step)
+			size := int64(math.MaxInt64)
+			if length.IsInt64() {
+				size = length.Int64()
+			}
+			if err := reserve(limits, sizeOf(size, elementSize)); err != nil {
+				return err
+			}
+			if size > maxLength {
+				return newError(object.RUNTIME_ERROR, "result of `range` is too long: %s elements (max %d)", length, maxLength)
+			}
+
+			result := make([]object.Object, size)
+			if start.IsInt64() && end.IsInt64() && step.IsInt64() {
+				// All of the elements are between `start` and `end`, so they fit in int64
+				for i := range result {
+					if err := poll(limits, i); err != nil {
+						return err
+					}
+					result[i] = &object.Integer{Value: start.Int64() + int64(i)*step.Int64()}
+				}
+				return &object.Array{Elements: result}
+			}
+
+			value := new(big.Int).Set(start)
+			for i := range result {
+				if err := poll(limits, i); err != nil {
+					return err
+				}
+				result[i] = newInteger(new(big.Int).Set(value))
+				value.Add(value, step)
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range arrayBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// Create `any` or `all`. They test each element by the optional function, or by its truthiness.
+// `any` stops at the first truthy result and `all` stops at the first falsy one.
+func quantifier(name string, stopAt bool) *object.Builtin {
+	return &object.Builtin{
+		HigherOrderFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs(name, args, 1, 2, object.ARRAY); err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				if err := checkCallable(name, args, 1); err != nil {
+					return err
+				}
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				result := el
+				if len(args) == 2 {
+					result = call(args[1], el)
+					if isErrorOrExit(result) {
+						return result
+					}
+				}
+				if isTruthy(result) == stopAt {
+					return nativeBoolToBooleanObject(stopAt)
+				}
+			}
+			return nativeBoolToBooleanObject(!stopAt)
+		},
+	}
+}
+
+// Expand nested arrays in `elements` up to `depth` levels.
+// `inProgress` holds the arrays being flattened to detect cycles.
+// The result is reserved as it grows, since shared arrays may expand exponentially.
+func flatten(limits *object.Limits, elements []object.Object, depth int64, inProgress map[*object.Array]bool) ([]object.Object, *object.Error) {
+	result := []object.Object{}
+	for i, el := range elements {
+		if err := poll(limits, i); err != nil {
+			return nil, err
+		}
+		array, ok := el.(*object.Array)
+		if !ok || depth <= 0 {
+			result = append(result, el)
+			continue
+		}
+		if inProgress[array] {
+			return nil, newError(object.VALUE_ERROR, "cannot flatten a cyclic array")
+		}
+		inProgress[array] = true
+		flattened, err := flatten(limits, array.Elements, depth-1, inProgress)
+		delete(inProgress, array)
+		if err != nil {
+			return nil, err
+		}
+		if err := reserve(limits, sizeOf(int64(len(result)+len(flattened)), elementSize)); err != nil {
+			return nil, err
+		}
+		result = append(result, flattened...)
+	}
+	return result, nil
+}
+
+// Check if the `i`-th argument of the builtin `name` can be called.
+func checkCallable(name string, args []object.Object, i int) *object.Error {
+	switch args[i].(type) {
+	case *object.Function, *object.Closure, *object.Builtin:
+		return nil
+	}
+	return newError(object.TYPE_ERROR, "argument %d to `%s` must be a function, got %s", i+1, name, args[i].Kind())
+}
+
+// Return the number of elements of `range(start, end, step)`.
+// It is computed in arbitrary precision since `end - start` may overflow int64.
+func rangeLength(start, end, step *big.Int) *big.Int {
+	distance := new(big.Int).Sub(end, start)
+	if distance.Sign() != 0 && distance.Sign() != step.Sign() {
+		return new(big.Int)
+	}
+	// ceil(distance / step), where both are of the same sign
+	length := new(big.Int).Add(distance, step)
+	length.Sub(length, big.NewInt(int64(step.Sign())))
+	return length.Quo(length, step)
+}
@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/object"
+)
+
+// The maximum count of `<<`. Larger shifts would allocate huge integers in one expression.
+const maxShift = 1 << 20
+
+// Return an `Integer` if `value` fits in int64, otherwise a `BigInteger`.
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInteger{Value: value}
+}
+
+// Judge if `obj` is an integer or a big integer.
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInteger:
+		return true
+	}
+	return false
+}
+
+// Convert an integer into *big.Int. `obj` must satisfy `isInteger`.
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	}
+	return new(big.Int)
+}
+
+// Judge if the result of `leftValue operator rightValue` overflows int64.
+func overflows(operator string, leftValue, rightValue int64) bool {
+	switch operator {
+	case "+":
+		sum := leftValue + rightValue
+		return (leftValue > 0 && rightValue > 0 && sum < 0) || (leftValue < 0 && rightValue < 0 && sum >= 0)
+	case "-":
+		diff := leftValue - rightValue
+		return (leftValue >= 0 && rightValue < 0 && diff < 0) || (leftValue < 0 && rightValue > 0 && diff >= 0)
+	case "*":
+		if leftValue == 0 || rightValue == 0 {
+			return false
+		}
+		if (leftValue == -1 && rightValue == math.MinInt64) || (rightValue == -1 && leftValue == math.MinInt64) {
+			return true
+		}
+		return (leftValue*rightValue)/rightValue != leftValue
+	case "/":
+		return leftValue == math.MinInt64 && rightValue == -1
+	case "<<":
+		return rightValue >= 0 && (rightValue >= 63 || (leftValue<<uint64(rightValue))>>uint64(rightValue) != leftValue)
+	}
+	return false
+}
+
+func evalBigIntegerInfixExpression(operator string, leftValue, rightValue *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return newInteger(new(big.Int).Add(leftValue, rightValue))
+	case "-":
+		return newInteger(new(big.Int).Sub(leftValue, rightValue))
+	case "*":
+		return newInteger(new(big.Int).Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by 0")
+		}
+		// Quo truncates toward zero like int64 division
+		return newInteger(new(big.Int).Quo(leftValue, rightValue))
+	case "%":
+		if rightValue.Sign() == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "modulo by 0")
+		}
+		return newInteger(new(big.Int).Rem(leftValue, rightValue))
+	case "&":
+		return newInteger(new(big.Int).And(leftValue, rightValue))
+	case "|":
+		return newInteger(new(big.Int).Or(leftValue, rightValue))
+	case "^":
+		return newInteger(new(big.Int).Xor(leftValue, rightValue))
+	case "<<":
+		if rightValue.Sign() < 0 {
+			return newError(object.VALUE_ERROR, "negative shift count: %s", rightValue)
+		}
+		if !rightValue.IsUint64() || rightValue.Uint64() > maxShift {
+			return newError(object.RUNTIME_ERROR, "shift count too large: %s (max %d)", rightValue, maxShift)
+		}
+		return newInteger(new(big.Int).Lsh(leftValue, uint(rightValue.Uint64())))
+	case ">>":
+		if rightValue.Sign() < 0 {
+			return newError(object.VALUE_ERROR, "negative shift count: %s", rightValue)
+		}
+		// Shifting out all of the bits leaves only the sign, however large the count is
+		if rightValue.Cmp(big.NewInt(int64(leftValue.BitLen()))) >= 0 {
+			if leftValue.Sign() < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: 0}
+		}
+		return newInteger(new(big.Int).Rsh(leftValue, uint(rightValue.Uint64())))
+	case "<":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) != 0)
+	default:
+		return NULL
+	}
+}
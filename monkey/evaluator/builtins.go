@@ -2,37 +2,48 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+// The maximum length of arrays and strings created by builtins at once.
+// It keeps a single call (e.g. `range(0, 1000000000)`) from exhausting the memory.
+const maxLength = 1 << 24
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Kind())
+				return newError(object.TYPE_ERROR, "argument to `len` not supported, got %s", args[0].Kind())
 			}
 		},
 	},
 	"exit": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) > 1 {
-				return newError("wrong number of arguments. got=%d, want<=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want<=1", len(args))
 			}
 
 			status := int(0)
 			if len(args) == 1 {
 				integ, ok := args[0].(*object.Integer)
 				if !ok {
-					return newError("argument to `exit` not supported, got %s", args[0].Kind())
+					return newError(object.TYPE_ERROR, "argument to `exit` not supported, got %s", args[0].Kind())
 				}
 				status = int(integ.Value)
 			}
@@ -43,7 +54,7 @@ var builtins = map[string]*object.Builtin{
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
@@ -52,14 +63,14 @@ var builtins = map[string]*object.Builtin{
 				}
 				return NULL
 			default:
-				return newError("arguments to `first` must be ARRAY, got %s", args[0].Kind())
+				return newError(object.TYPE_ERROR, "arguments to `first` must be ARRAY, got %s", args[0].Kind())
 			}
 		},
 	},
 	"last": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
@@ -68,14 +79,14 @@ var builtins = map[string]*object.Builtin{
 				}
 				return NULL
 			default:
-				return newError("arguments to `last` must be ARRAY, got %s", args[0].Kind())
+				return newError(object.TYPE_ERROR, "arguments to `last` must be ARRAY, got %s", args[0].Kind())
 			}
 		},
 	},
 	"rest": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
@@ -86,14 +97,14 @@ var builtins = map[string]*object.Builtin{
 				}
 				return NULL
 			default:
-				return newError("arguments to `rest` must be ARRAY, got %s", args[0].Kind())
+				return newError(object.TYPE_ERROR, "arguments to `rest` must be ARRAY, got %s", args[0].Kind())
 			}
 		},
 	},
 	"push": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
@@ -103,7 +114,7 @@ var builtins = map[string]*object.Builtin{
 				newElements[length] = args[1]
 				return &object.Array{Elements: newElements}
 			default:
-				return newError("arguments to `push` must be ARRAY, got %s", args[0].Kind())
+				return newError(object.TYPE_ERROR, "arguments to `push` must be ARRAY, got %s", args[0].Kind())
 			}
 		},
 	},
@@ -116,14 +127,149 @@ var builtins = map[string]*object.Builtin{
 			return NULL
 		},
 	},
+	"str": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
 	"kind": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			fmt.Println(args[0].Kind())
 
 			return NULL
 		},
 	},
+	"int": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				return floatToInteger(math.Trunc(arg.Value))
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			case *object.String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+				if !ok {
+					return newError(object.VALUE_ERROR, "could not parse %q as integer", arg.Value)
+				}
+				return newInteger(value)
+			default:
+				return newError(object.TYPE_ERROR, "argument to `int` not supported, got %s", args[0].Kind())
+			}
+		},
+	},
+	"float": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger, *object.Float:
+				return &object.Float{Value: toFloat(arg)}
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError(object.VALUE_ERROR, "could not parse %q as float", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError(object.TYPE_ERROR, "argument to `float` not supported, got %s", args[0].Kind())
+			}
+		},
+	},
+	"round": roundingBuiltin("round", math.Round),
+	"floor": roundingBuiltin("floor", math.Floor),
+	"ceil":  roundingBuiltin("ceil", math.Ceil),
+}
+
+// Create a builtin which rounds a number to an integer by `round`.
+func roundingBuiltin(name string, round func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				return floatToInteger(round(arg.Value))
+			default:
+				return newError(object.TYPE_ERROR, "argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Kind())
+			}
+		},
+	}
+}
+
+// Convert an integral float into an integer. It is promoted to a big integer if it is out of int64 range.
+func floatToInteger(value float64) object.Object {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return newError(object.VALUE_ERROR, "cannot convert %s to INTEGER", (&object.Float{Value: value}).Inspect())
+	}
+	if value < math.MinInt64 || value >= math.MaxInt64 {
+		integ, _ := big.NewFloat(value).Int(nil)
+		return newInteger(integ)
+	}
+	return &object.Integer{Value: int64(value)}
+}
+
+// Check if the number of arguments `got` is from `min` to `max`. `max` is negative if unlimited.
+func checkArity(got, min, max int) *object.Error {
+	switch {
+	case got >= min && (max < 0 || got <= max):
+		return nil
+	case max < 0:
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want>=%d", got, min)
+	case min == max:
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d", got, min)
+	default:
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d..%d", got, min, max)
+	}
+}
+
+// Check the number and the kinds of the arguments of the builtin `name`.
+// It takes from `min` to `max` arguments whose kinds are `kinds` respectively.
+// INTEGER also accepts BIG_INTEGER, which is read by `clampInteger`.
+func checkArgs(name string, args []object.Object, min, max int, kinds ...object.ObjectKind) *object.Error {
+	if err := checkArity(len(args), min, max); err != nil {
+		return err
+	}
+
+	for i, arg := range args {
+		if i >= len(kinds) || arg.Kind() == kinds[i] || (kinds[i] == object.INTEGER && isInteger(arg)) {
+			continue
+		}
+		return newError(object.TYPE_ERROR, "argument %d to `%s` must be %s, got %s", i+1, name, kinds[i], arg.Kind())
+	}
+	return nil
+}
+
+// Return the value of an integer argument, saturating a BIG_INTEGER to the range of int64.
+// `obj` must satisfy `isInteger`.
+func clampInteger(obj object.Object) int64 {
+	value := toBigInt(obj)
+	switch {
+	case value.IsInt64():
+		return value.Int64()
+	case value.Sign() < 0:
+		return math.MinInt64
+	default:
+		return math.MaxInt64
+	}
 }
@@ -0,0 +1,16 @@
+package evaluator
+
+import (
+	"testing"
+
+	"monkey/enginetest"
+	"monkey/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineCases(t *testing.T) {
+	enginetest.Run(t, func(input string) (object.Object, error) {
+		return testEval(assert.New(t), input), nil
+	})
+}
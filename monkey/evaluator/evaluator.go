@@ -2,17 +2,31 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := step(env.Limits()); err != nil {
+		return withPosition(err, node)
+	}
+	return withPosition(eval(node, env), node)
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -28,12 +42,50 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isErrorOrExit(val) {
+			return val
+		}
+		return thrownError(val)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.ImportExpression:
+		path := Eval(node.Path, env)
+		if isErrorOrExit(path) {
+			return path
+		}
+		return evalImport(path, node.Pos().Filename, env.Runtime())
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.LetStatement:
+		if env.IsConst(node.Name.Value) {
+			return newError(object.TYPE_ERROR, "cannot assign to constant: %s", node.Name.Value)
+		}
 		val := Eval(node.Value, env)
 		if isErrorOrExit(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Token.Kind == token.Const {
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
+	case *ast.AssignExpression:
+		switch target := node.Target.(type) {
+		case *ast.Identifier:
+			return evalIdentifierAssignment(node, target, env)
+		case *ast.IndexExpression:
+			return evalIndexAssignment(node, target, env)
+		}
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isErrorOrExit(right) {
@@ -41,6 +93,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isErrorOrExit(left) {
 			return left
@@ -49,29 +104,32 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isErrorOrExit(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		if err := reserveInfix(env.Limits(), node.Operator, left, right); err != nil {
+			return err
+		}
+		return allocate(env.Limits(), evalInfixExpression(node.Operator, left, right))
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isErrorOrExit(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isErrorOrExit(args[0]) {
-			return args[0]
+		args, err := evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
 		}
-		return applyFunction(function, args)
+		return traceCall(applyFunction(function, args, env.Limits()), function, node)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isErrorOrExit(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(env.Limits(), &object.Array{Elements: elements})
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return allocate(env.Limits(), evalHashLiteral(node, env))
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isErrorOrExit(left) {
@@ -82,12 +140,25 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return allocate(env.Limits(), evalSliceExpression(node, env))
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		parts := evalExpressions(node.Parts, env)
+		if len(parts) == 1 && isErrorOrExit(parts[0]) {
+			return parts[0]
+		}
+		return allocate(env.Limits(), interpolate(parts))
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	}
@@ -119,7 +190,7 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
-		case *object.ReturnValue, *object.Error, *object.Exit:
+		case *object.ReturnValue, *object.Error, *object.Exit, *object.Break, *object.Continue:
 			return result
 		}
 	}
@@ -129,6 +200,9 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
+	if isErrorOrExit(condition) {
+		return condition
+	}
 
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
@@ -139,6 +213,125 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+
+	if errObj, ok := result.(*object.Error); ok && te.CatchBlock != nil && !isAborted(errObj) {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(te.CatchParam.Value, caughtValue(errObj))
+		result = Eval(te.CatchBlock, catchEnv)
+	}
+
+	if te.Finally != nil {
+		// `finally` overrides the result only if it stops the execution
+		finallyResult := Eval(te.Finally, env)
+		switch finallyResult.(type) {
+		case *object.ReturnValue, *object.Error, *object.Exit, *object.Break, *object.Continue:
+			return finallyResult
+		}
+	}
+
+	return result
+}
+
+// Return the error raised by `throw <val>`.
+func thrownError(val object.Object) *object.Error {
+	return &object.Error{ErrorKind: object.USER_ERROR, Message: val.Inspect(), Value: val}
+}
+
+// Return the value bound to the parameter of `catch`.
+// Errors raised by the interpreter are converted into a hash of their kind and message.
+func caughtValue(errObj *object.Error) object.Object {
+	if errObj.Value != nil {
+		return errObj.Value
+	}
+
+	kind := &object.String{Value: "kind"}
+	message := &object.String{Value: "message"}
+	hash := object.NewHash()
+	hash.Set(kind, &object.String{Value: errObj.ErrorKind.String()})
+	hash.Set(message, &object.String{Value: errObj.Message})
+	return hash
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isErrorOrExit(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(ws.Body, env)
+		if result, stop := loopResult(result); stop {
+			return result
+		}
+	}
+}
+
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isErrorOrExit(iterable) {
+		return iterable
+	}
+
+	items, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// Bind the variable for each iteration so that closures capture the current one
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fs.Variable.Value, item)
+
+		result := Eval(fs.Body, loopEnv)
+		if result, stop := loopResult(result); stop {
+			return result
+		}
+	}
+	return NULL
+}
+
+// Return the items which `for-in` visits: elements of an array, characters of a string or keys of a hash.
+func iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items := make([]object.Object, len(iterable.Elements))
+		copy(items, iterable.Elements)
+		return items, nil
+	case *object.String:
+		items := []object.Object{}
+		for _, ch := range iterable.Value {
+			items = append(items, &object.String{Value: string(ch)})
+		}
+		return items, nil
+	case *object.Hash:
+		items := []object.Object{}
+		for _, pair := range iterable.OrderedPairs() {
+			items = append(items, pair.Key)
+		}
+		return items, nil
+	default:
+		return nil, newError(object.TYPE_ERROR, "not iterable: %s", iterable.Kind())
+	}
+}
+
+// Handle the result of a loop body. It returns true with the result of the loop
+// if the loop should stop.
+func loopResult(result object.Object) (object.Object, bool) {
+	switch result.(type) {
+	case *object.Break:
+		return NULL, true
+	case *object.ReturnValue, *object.Error, *object.Exit:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -148,8 +341,13 @@ func isTruthy(obj object.Object) bool {
 	case FALSE:
 		return false
 	default:
-		if integ, ok := obj.(*object.Integer); ok && integ.Value != 0 {
-			return true
+		switch obj := obj.(type) {
+		case *object.Integer:
+			return obj.Value != 0
+		case *object.BigInteger:
+			return obj.Value.Sign() != 0
+		case *object.Float:
+			return obj.Value != 0
 		}
 		return false
 	}
@@ -162,7 +360,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Kind())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Kind())
 	}
 }
 
@@ -171,10 +369,18 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if integ, ok := right.(*object.Integer); ok {
-		return &object.Integer{Value: -integ.Value}
-	} else {
-		return newError("unknown operator: -%s", right.Kind())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return newInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return newInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Kind())
 	}
 }
 
@@ -184,17 +390,90 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 		leftValue := left.(*object.Integer).Value
 		rightValue := right.(*object.Integer).Value
 		return evalIntegerInfixExpression(operator, leftValue, rightValue)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntegerInfixExpression(operator, toBigInt(left), toBigInt(right))
+	case isNumber(left) && isNumber(right):
+		if isBitwiseOperator(operator) {
+			return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Kind(), operator, right.Kind())
+		}
+		// Either of operands is a float. The other one is converted to a float.
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
 	case left.Kind() == object.STRING && right.Kind() == object.STRING:
 		leftValue := left.(*object.String).Value
 		rightValue := right.(*object.String).Value
 		return evalStringInfixExpression(operator, leftValue, rightValue)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Kind(), operator, right.Kind())
+	}
+}
+
+// Judge if `left` and `right` are structurally equal.
+// Arrays and hashes are compared by their contents, and functions by identity.
+// Objects of different kinds are not equal (except integers and floats).
+func objectsEqual(left, right object.Object) bool {
+	return containersEqual(left, right, map[[2]object.Object]bool{})
+}
+
+// Compare `left` and `right` like `objectsEqual`. `inProgress` holds the pairs of arrays or
+// hashes being compared. A pair reached again is regarded as equal, so cycles terminate.
+func containersEqual(left, right object.Object, inProgress map[[2]object.Object]bool) bool {
+	if left == right {
+		return true
+	}
+	pair := [2]object.Object{left, right}
+	if inProgress[pair] {
+		return true
+	}
+	inProgress[pair] = true
+	defer delete(inProgress, pair)
+
+	switch left := left.(type) {
+	case *object.Array:
+		right, ok := right.(*object.Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i, el := range left.Elements {
+			if !valuesEqual(el, right.Elements[i], inProgress) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		// The insertion order does not matter
+		right, ok := right.(*object.Hash)
+		if !ok || len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		for hashKey, pair := range left.Pairs {
+			other, ok := right.Pairs[hashKey]
+			if !ok || !valuesEqual(pair.Value, other.Value, inProgress) {
+				return false
+			}
+		}
+		return true
+	case *object.Null:
+		_, ok := right.(*object.Null)
+		return ok
+	case *object.Boolean:
+		right, ok := right.(*object.Boolean)
+		return ok && left.Value == right.Value
 	default:
-		return newError("unknown operator: %s %s %s", left.Kind(), operator, right.Kind())
+		return false
+	}
+}
+
+// Judge if elements of arrays or values of hashes are equal in the same way as `==`.
+func valuesEqual(left, right object.Object, inProgress map[[2]object.Object]bool) bool {
+	switch left.(type) {
+	case *object.Array, *object.Hash:
+		return containersEqual(left, right, inProgress)
 	}
+	return evalInfixExpression("==", left, right) == TRUE
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -210,70 +489,382 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Evaluate arguments of a call. Arrays spread by `...` are expanded into their elements.
+func evalArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, object.Object) {
+	args := []object.Object{}
+
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok {
+			evaluated := Eval(e, env)
+			if isErrorOrExit(evaluated) {
+				return nil, evaluated
+			}
+			args = append(args, evaluated)
+			continue
+		}
+
+		evaluated := Eval(spread.Value, env)
+		if isErrorOrExit(evaluated) {
+			return nil, evaluated
+		}
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			return nil, withPosition(newError(object.TYPE_ERROR, "spread argument must be ARRAY, got %s", evaluated.Kind()), spread)
+		}
+		args = append(args, array.Elements...)
+	}
+	return args, nil
+}
+
+// Call `fn` with `args`. Tail calls made by `fn` are performed in a loop (trampolining),
+// so tail-recursive functions run in constant Go stack space.
+// Other nested calls are limited to `Limits.MaxDepth` not to overflow the Go stack.
+// Builtins are called under `limits`, which is nil if they are not enforced.
+func applyFunction(fn object.Object, args []object.Object, limits *object.Limits) object.Object {
+	if function, ok := fn.(*object.Function); ok {
+		limits = function.Env.Limits()
+		if limits.MaxDepth > 0 && limits.Depth >= limits.MaxDepth {
+			name := function.Name
+			if name == "" {
+				name = "<anonymous>"
+			}
+			return newError(object.RUNTIME_ERROR, "maximum recursion depth exceeded at fn %s", name)
+		}
+		limits.Depth++
+		defer func() { limits.Depth-- }()
+	}
+
+	result := applyFunctionOnce(fn, args, limits)
+	for {
+		tailCall, ok := result.(*object.TailCall)
+		if !ok {
+			return result
+		}
+		result = applyFunctionOnce(tailCall.Function, tailCall.Arguments, limits)
+		// Only the last tail call is recorded in the stack trace
+		result = withPosition(traceCall(result, tailCall.Function, tailCall.Call), tailCall.Call)
+	}
+}
+
+// Call `fn` once. The result is a `TailCall` if `fn` ends with a call.
+func applyFunctionOnce(fn object.Object, args []object.Object, limits *object.Limits) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendedFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		extendedEnv, err := extendedFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		evaluated := evalFunctionBody(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		// The result of a builtin is newly allocated in most cases
+		return allocate(limits, fn.Call(callerWith(limits), limits, args...))
 
 	default:
-		return newError("not a function: %s", fn.Kind())
+		return newError(object.TYPE_ERROR, "not a function: %s", fn.Kind())
+	}
+}
+
+// Return a caller for builtins (e.g. for the callback of `map`) which calls functions under `limits`.
+func callerWith(limits *object.Limits) object.Caller {
+	return func(fn object.Object, args ...object.Object) object.Object {
+		return applyFunction(fn, args, limits)
 	}
 }
 
-func extendedFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// Record the call in the stack trace if an error propagates from the body of `fn`.
+func traceCall(result object.Object, fn object.Object, call *ast.CallExpression) object.Object {
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return result
+	}
+
+	// An error without position is raised by the call itself (e.g. "not a function")
+	if function, ok := fn.(*object.Function); ok && errObj.Pos.IsValid() {
+		frame := object.StackFrame{Function: function.Name, CallSite: call.Function.Pos()}
+		errObj.Stack = append(errObj.Stack, frame)
+	}
+	return errObj
+}
+
+// Bind `args` to the parameters of `fn`. Defaults of omitted parameters are evaluated
+// in order, so they can refer to the preceding parameters.
+func extendedFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	required := len(fn.Parameters)
+	for required > 0 && required <= len(fn.Defaults) && fn.Defaults[required-1] != nil {
+		required--
+	}
+	max := len(fn.Parameters)
+	if fn.Rest != nil {
+		max = -1
+	}
+	if err := checkArity(len(args), required, max); err != nil {
+		return nil, err
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		value := Eval(fn.Defaults[paramIdx], env)
+		if isErrorOrExit(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
 	}
+	if obj == nil {
+		// The body is empty
+		return NULL
+	}
 	return obj
 }
 
+// Update the variable in the scope where it is defined.
+func evalIdentifierAssignment(node *ast.AssignExpression, target *ast.Identifier, env *object.Environment) object.Object {
+	scope, ok := env.Scope(target.Value)
+	if !ok {
+		return newError(object.NAME_ERROR, "identifier not found: %s", target.Value)
+	}
+	if scope.IsConst(target.Value) {
+		return newError(object.TYPE_ERROR, "cannot assign to constant: %s", target.Value)
+	}
+
+	current, _ := scope.Get(target.Value)
+	val := Eval(node.Value, env)
+	if isErrorOrExit(val) {
+		return val
+	}
+
+	val = allocate(env.Limits(), evalCompoundAssignment(node.Operator, current, val))
+	if isErrorOrExit(val) {
+		return val
+	}
+	return scope.Set(target.Value, val)
+}
+
+// Update an element of an array or a hash in place.
+func evalIndexAssignment(node *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if isErrorOrExit(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if isErrorOrExit(index) {
+		return index
+	}
+
+	var current object.Object
+	if node.Operator != "=" {
+		current = evalIndexExpression(left, index)
+		if isErrorOrExit(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isErrorOrExit(val) {
+		return val
+	}
+	val = allocate(env.Limits(), evalCompoundAssignment(node.Operator, current, val))
+	if isErrorOrExit(val) {
+		return val
+	}
+	return setIndex(left, index, val, env.Limits())
+}
+
+// Store `val` as the element of `left` at `index` and return it.
+// A new pair of a hash is counted against `limits` if not nil.
+func setIndex(left, index, val object.Object, limits *object.Limits) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if left.Frozen {
+			return newError(object.TYPE_ERROR, "cannot modify frozen ARRAY")
+		}
+		if index.Kind() == object.BIG_INTEGER {
+			return newError(object.INDEX_ERROR, "index out of range: %s", index.Inspect())
+		}
+		integ, ok := index.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "array index must be INTEGER, got %s", index.Kind())
+		}
+		if integ.Value < 0 || integ.Value >= int64(len(left.Elements)) {
+			return newError(object.INDEX_ERROR, "index out of range: %d", integ.Value)
+		}
+		left.Elements[integ.Value] = val
+	case *object.Hash:
+		if left.Frozen {
+			return newError(object.TYPE_ERROR, "cannot modify frozen HASH")
+		}
+		if !object.IsHashable(index) {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Kind())
+		}
+		if _, ok := left.Get(index); !ok {
+			if err := charge(limits, pairSize); err != nil {
+				return err
+			}
+		}
+		left.Set(index, val)
+	default:
+		return newError(object.TYPE_ERROR, "index assignment not supported: %s", left.Kind())
+	}
+	return val
+}
+
+// Return the value to be assigned by `operator` (e.g. `current + val` for `+=`).
+func evalCompoundAssignment(operator string, current, val object.Object) object.Object {
+	if operator == "=" {
+		return val
+	}
+	// Strip the trailing "="
+	return evalInfixExpression(operator[:len(operator)-1], current, val)
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isErrorOrExit(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", key.Kind())
+		if !object.IsHashable(key) {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Kind())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isErrorOrExit(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(key, value)
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Kind() == object.ARRAY && index.Kind() == object.INTEGER:
 		return evalArrayIndexExpression(left.(*object.Array), index.(*object.Integer))
+	case left.Kind() == object.ARRAY && index.Kind() == object.BIG_INTEGER:
+		// A big integer never fits in the range of indices
+		return NULL
+	case left.Kind() == object.STRING && index.Kind() == object.INTEGER:
+		return evalStringIndexExpression(left.(*object.String), index.(*object.Integer))
+	case left.Kind() == object.STRING && index.Kind() == object.BIG_INTEGER:
+		return NULL
 	case left.Kind() == object.HASH:
 		return evalHashIndexExpression(left.(*object.Hash), index)
 	default:
-		return newError("index operator not supported: %s", left.Kind())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Kind())
+	}
+}
+
+// Return the character (as a string) at the rune index `index`.
+func evalStringIndexExpression(str *object.String, index *object.Integer) object.Object {
+	runes := []rune(str.Value)
+	if index.Value < 0 || index.Value >= int64(len(runes)) {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[index.Value])}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isErrorOrExit(left) {
+		return left
+	}
+
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{node.Start, node.End} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if isErrorOrExit(bounds[i]) {
+			return bounds[i]
+		}
+	}
+	return evalSlice(left, bounds[0], bounds[1])
+}
+
+// Return `left[start:end]`. Missing bounds are `NULL`.
+// Bounds out of range are clamped, so the result is empty rather than an error.
+func evalSlice(left, start, end object.Object) object.Object {
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = utf8.RuneCountInString(left.Value)
+	default:
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Kind())
+	}
+
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return err
+	}
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return err
+	}
+	if to < from {
+		to = from
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return &object.Array{Elements: elements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[from:to])}
+	}
+}
+
+// Convert a slice bound into an index within [0, length]. `NULL` means `defaultValue`.
+func sliceBound(bound object.Object, defaultValue, length int) (int, *object.Error) {
+	switch bound := bound.(type) {
+	case *object.Null:
+		return defaultValue, nil
+	case *object.Integer:
+		if bound.Value < 0 {
+			return 0, nil
+		}
+		if bound.Value > int64(length) {
+			return length, nil
+		}
+		return int(bound.Value), nil
+	case *object.BigInteger:
+		if bound.Value.Sign() < 0 {
+			return 0, nil
+		}
+		return length, nil
+	default:
+		return 0, newError(object.TYPE_ERROR, "slice bound must be INTEGER, got %s", bound.Kind())
 	}
 }
 
@@ -287,17 +878,16 @@ func evalArrayIndexExpression(array *object.Array, index *object.Integer) object
 }
 
 func evalHashIndexExpression(hash *object.Hash, index object.Object) object.Object {
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Kind())
+	if !object.IsHashable(index) {
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Kind())
 	}
 
-	pair, ok := hash.Pairs[key.HashKey()]
+	value, ok := hash.Get(index)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
@@ -306,14 +896,24 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
+	if builtin, ok := env.Runtime().Builtins[node.Value]; ok {
+		return builtin
+	}
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 }
 
 func evalIntegerInfixExpression(operator string, leftValue, rightValue int64) object.Object {
+	if (operator == "<<" || operator == ">>") && rightValue < 0 {
+		return newError(object.VALUE_ERROR, "negative shift count: %d", rightValue)
+	}
+	if overflows(operator, leftValue, rightValue) {
+		return evalBigIntegerInfixExpression(operator, big.NewInt(leftValue), big.NewInt(rightValue))
+	}
+
 	switch operator {
 	case "+":
 		return &object.Integer{Value: leftValue + rightValue}
@@ -323,13 +923,32 @@ func evalIntegerInfixExpression(operator string, leftValue, rightValue int64) ob
 		return &object.Integer{Value: leftValue * rightValue}
 	case "/":
 		if rightValue == 0 {
-			return newError("division by 0")
+			return newError(object.ZERO_DIVISION_ERROR, "division by 0")
 		}
 		return &object.Integer{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "modulo by 0")
+		}
+		return &object.Integer{Value: leftValue % rightValue}
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<":
+		return &object.Integer{Value: leftValue << uint64(rightValue)}
+	case ">>":
+		return &object.Integer{Value: leftValue >> uint64(rightValue)}
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 	case ">":
 		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
@@ -339,12 +958,118 @@ func evalIntegerInfixExpression(operator string, leftValue, rightValue int64) ob
 	}
 }
 
-func evalStringInfixExpression(operator string, leftValue, rightValue string) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", object.STRING, operator, object.STRING)
+func evalFloatInfixExpression(operator string, leftValue, rightValue float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by 0")
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "modulo by 0")
+		}
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return NULL
 	}
+}
 
-	return &object.String{Value: leftValue + rightValue}
+// Judge if `operator` is only applicable to integers.
+func isBitwiseOperator(operator string) bool {
+	switch operator {
+	case "&", "|", "^", "<<", ">>":
+		return true
+	}
+	return false
+}
+
+// Judge if `obj` is an integer, a big integer or a float.
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInteger, *object.Float:
+		return true
+	}
+	return false
+}
+
+// Convert a number into float64. `obj` must satisfy `isNumber`.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+// Evaluate `&&` and `||`. The right operand is evaluated only if the left one does not decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isErrorOrExit(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+
+	right := Eval(node.Right, env)
+	if isErrorOrExit(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// Concatenate the parts of an interpolated string converted by `Inspect`.
+func interpolate(parts []object.Object) *object.String {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(part.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+func evalStringInfixExpression(operator string, leftValue, rightValue string) object.Object {
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.STRING, operator, object.STRING)
+	}
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -361,6 +1086,15 @@ func isErrorOrExit(obj object.Object) bool {
 	return false
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+// Attach the position of `node` to an error which doesn't know where it occurred.
+// Since errors propagate from inner nodes, the innermost position is recorded.
+func withPosition(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return obj
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{ErrorKind: kind, Message: fmt.Sprintf(format, a...)}
 }
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"runtime/debug"
 	"testing"
 
 	"monkey/lexer"
@@ -10,148 +11,195 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestEvalIntegerExpression(t *testing.T) {
+func TestShortCircuit(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
 		input    string
-		expected int
+		expected interface{}
 	}{
-		{"5", 5},
-		{"10", 10},
-		{"-5", -5},
-		{"-10", -10},
-		{"5 + 5 + 5 + 5 - 10", 10},
-		{"2 * 2 * 2 * 2 * 2", 32},
-		{"-50 + 100 + -50", 0},
-		{"5 * 2 + 10", 20},
-		{"5 + 2 * 10", 25},
-		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
-		{"2 * (5 + 10)", 30},
-		{"3 * 3 * 3 + 10", 37},
-		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"true && undefined", "identifier not found: undefined"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(a, tt.input)
+		if expected, ok := tt.expected.(string); ok {
+			errObj, ok := evaluated.(*object.Error)
+			if a.True(ok, tt.input) {
+				a.Equal(expected, errObj.Message)
+			}
+			continue
+		}
 		testObject(a, evaluated, tt.expected)
 	}
 }
 
-func TestEvalBooleanExpression(t *testing.T) {
+func TestErrorPosition(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
-		input    string
-		expected bool
+		input          string
+		expectedLine   int
+		expectedColumn int
 	}{
-		{"true", true},
-		{"false", false},
-		{"1 < 2", true},
-		{"1 > 2", false},
-		{"1 < 1", false},
-		{"1 > 1", false},
-		{"1 == 1", true},
-		{"1 != 1", false},
-		{"1 == 2", false},
-		{"1 != 2", true},
-		{"true == true", true},
-		{"false == false", true},
-		{"true == false", false},
-		{"true != false", true},
-		{"false != true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
+		{"let x = 1;\nlet y = -true;", 2, 9},
+		{"let f = fn() {\n  foobar\n};\nf()", 2, 3},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(a, tt.input)
-		testObject(a, evaluated, tt.expected)
+		errObj, ok := evaluated.(*object.Error)
+		if !a.True(ok) {
+			continue
+		}
+
+		a.Equal(tt.expectedLine, errObj.Pos.Line, tt.input)
+		a.Equal(tt.expectedColumn, errObj.Pos.Column, tt.input)
 	}
 }
 
-func TestBangOperator(t *testing.T) {
+func TestErrorStackTrace(t *testing.T) {
 	a := assert.New(t)
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!0", true},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
+	input := `let inner = fn(x) {
+  x + y
+};
+let outer = fn(x) {
+  let r = inner(x);
+  r
+};
+outer(1);`
+
+	evaluated := testEval(a, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !a.True(ok) {
+		return
 	}
 
-	for _, tt := range tests {
-		evaluated := testEval(a, tt.input)
-		testObject(a, evaluated, tt.expected)
+	a.Equal(object.NAME_ERROR, errObj.ErrorKind)
+	if !a.Len(errObj.Stack, 2) {
+		return
 	}
+	a.Equal("inner", errObj.Stack[0].Function)
+	a.Equal(5, errObj.Stack[0].CallSite.Line)
+	a.Equal(11, errObj.Stack[0].CallSite.Column)
+	a.Equal("outer", errObj.Stack[1].Function)
+	a.Equal(8, errObj.Stack[1].CallSite.Line)
+	a.Equal(1, errObj.Stack[1].CallSite.Column)
 }
 
-func TestIfElseExpression(t *testing.T) {
+func TestTailCalls(t *testing.T) {
 	a := assert.New(t)
+	// Deep recursion overflows this stack unless tail calls are eliminated
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (0) { 10 }", nil},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"let count = fn(n, acc) { if (n == 0) { return acc } count(n - 1, acc + 1) }; count(200000, 0)", 200000},
+		{"let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(200000)", 0},
+		{"let count = fn(n) { if (n > 0) { return count(n - 1) } n }; count(200000)", 0},
+		{
+			`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+			let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+			even(200001)`,
+			false,
+		},
+		{"let sum = fn(xs, acc = 0) { if (len(xs) == 0) { return acc } sum(rest(xs), acc + first(xs)) }; sum([1, 2, 3])", 6},
+		{"let f = fn(...xs) { len(...xs) }; f([1, 2])", 2},
+		{"let f = fn(n) { if (n == 0) { return 7 } let r = n; f(r - 1) }; f(3)", 7},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(a, tt.input)
 		testObject(a, evaluated, tt.expected)
 	}
+}
 
+func TestTailCallErrors(t *testing.T) {
+	a := assert.New(t)
+	input := `let inner = fn(x) {
+  x + y
+};
+let outer = fn(x) {
+  inner(x)
+};
+outer(1);`
+
+	evaluated := testEval(a, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !a.True(ok) {
+		return
+	}
+	a.Equal(2, errObj.Pos.Line)
+	if !a.Len(errObj.Stack, 2) {
+		return
+	}
+	a.Equal("inner", errObj.Stack[0].Function)
+	a.Equal(5, errObj.Stack[0].CallSite.Line)
+	a.Equal("outer", errObj.Stack[1].Function)
+	a.Equal(7, errObj.Stack[1].CallSite.Line)
+
+	evaluated = testEval(a, "let f = fn() {\n  g(1)\n};\nlet g = fn(a, b) { a };\nf()")
+	errObj, ok = evaluated.(*object.Error)
+	if !a.True(ok) {
+		return
+	}
+	a.Equal("wrong number of arguments. got=1, want=2", errObj.Message)
+	a.Equal(2, errObj.Pos.Line)
+	a.Equal(4, errObj.Pos.Column)
 }
 
-func TestReturnStatements(t *testing.T) {
+func TestRecursionDepthLimit(t *testing.T) {
 	a := assert.New(t)
+
+	evaluated := testEval(a, "let f = fn(n) { 1 + f(n + 1) }; f(0)")
+	errObj, ok := evaluated.(*object.Error)
+	if !a.True(ok) {
+		return
+	}
+	a.Equal(object.RUNTIME_ERROR, errObj.ErrorKind)
+	a.Equal("maximum recursion depth exceeded at fn f", errObj.Message)
+	a.Len(errObj.Stack, object.DefaultMaxDepth)
+
+	env := object.NewEnvironment()
+	env.Limits().MaxDepth = 5
 	tests := []struct {
 		input    string
-		expected int64
+		expected interface{}
 	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2 * 5;", 10},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(4)", 4},
+		{"f(5)", "maximum recursion depth exceeded at fn f"},
+		// The depth is restored after the error
+		{"f(4)", 4},
+		{"let g = fn(n) { if (n == 0) { 0 } else { g(n - 1) } }; g(100)", 0},
+		{"let h = fn(n) { 1 + fn() { 1 + h(n) }() }; h(0)", "maximum recursion depth exceeded at fn <anonymous>"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(a, tt.input)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, env)
+		if message, ok := tt.expected.(string); ok {
+			errObj, ok := evaluated.(*object.Error)
+			if a.True(ok, tt.input) {
+				a.Equal(message, errObj.Message)
+			}
+			continue
+		}
 		testObject(a, evaluated, tt.expected)
 	}
 }
 
-func TestErrorHandling(t *testing.T) {
+func TestErrorKind(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
-		input           string
-		expectedMessage string
+		input        string
+		expectedKind object.ErrorKind
 	}{
-		{"5 + true", "unknown operator: INTEGER + BOOLEAN"},
-		{"5 + true; 5;", "unknown operator: INTEGER + BOOLEAN"},
-		{"-true", "unknown operator: -BOOLEAN"},
-		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"5; true + false; 5;", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
-		{
-			"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{"foobar", "identifier not found: foobar"},
-		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"1 / 0", object.ZERO_DIVISION_ERROR},
+		{`len("a", "b")`, object.ARGUMENT_ERROR},
+		{`format("%d", 1.5)`, object.TYPE_ERROR},
+		{`format("%s")`, object.VALUE_ERROR},
 	}
 
 	for _, tt := range tests {
@@ -160,164 +208,88 @@ func TestErrorHandling(t *testing.T) {
 		if !a.True(ok) {
 			continue
 		}
-
-		a.Equal(errObj.Message, tt.expectedMessage)
+		a.Equal(tt.expectedKind, errObj.ErrorKind, tt.input)
 	}
 }
 
-func TestLetStatements(t *testing.T) {
+func TestTryCatch(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
 		input    string
-		expected int64
+		expected interface{}
 	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{`let f = fn() { 1 + f() }; try { f() } catch (e) { e["kind"] }`, "RuntimeError"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(a, tt.input)
+		if expected, ok := tt.expected.(string); ok {
+			str, ok := evaluated.(*object.String)
+			if a.True(ok, tt.input) {
+				a.Equal(expected, str.Value)
+			}
+			continue
+		}
 		testObject(a, evaluated, tt.expected)
 	}
 }
 
-func TestBuiltinFunction(t *testing.T) {
+func TestAssignErrors(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
-		input    string
-		expected interface{}
+		input           string
+		expectedMessage string
 	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hwllo world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
-		{`len([3, 3, 4])`, 3},
-		{`first([3, 3, 4])`, 3},
-		{`last([3, 3, 4])`, 4},
-		{`rest([3, 3, 4])`, []int{3, 4}},
-		{`push([3, 3, 4], 5)`, []int{3, 3, 4, 5}},
+		{`let h = {}; h[fn() {}] = 1`, "unusable as hash key: FUNCTION"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(a, tt.input)
-
-		switch expected := tt.expected.(type) {
-		case int:
-			testObject(a, evaluated, expected)
-		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !a.True(ok) {
-				continue
-			}
-			a.Equal(errObj.Message, expected)
-		case []int:
-			arrayObj, ok := evaluated.(*object.Array)
-			if !a.True(ok) {
-				continue
-			}
-			for i, v := range expected {
-				testObject(a, arrayObj.Elements[i], v)
-			}
+		errObj, ok := evaluated.(*object.Error)
+		if !a.True(ok, tt.input) {
+			continue
 		}
+		a.Equal(tt.expectedMessage, errObj.Message)
 	}
 }
 
-func TestStringLiteral(t *testing.T) {
+func TestForInErrors(t *testing.T) {
 	a := assert.New(t)
-	input := `"Hello World!"`
-	evaluated := testEval(a, input)
-	str, ok := evaluated.(*object.String)
+	evaluated := testEval(a, "for (x in 5) { x }")
+	errObj, ok := evaluated.(*object.Error)
 	if !a.True(ok) {
 		return
 	}
-
-	a.Equal(str.Value, "Hello World!")
+	a.Equal("not iterable: INTEGER", errObj.Message)
 }
 
-func TestArrayLiterals(t *testing.T) {
+func TestStringInterpolation(t *testing.T) {
 	a := assert.New(t)
-	input := `[1, 2 + 2, 3 * 3]`
-	evaluated := testEval(a, input)
-	ao, ok := evaluated.(*object.Array)
-	if !a.True(ok) {
-		return
+	evaluated := testEval(a, `"a ${missing} b"`)
+	errObj, ok := evaluated.(*object.Error)
+	if a.True(ok) {
+		a.Equal("identifier not found: missing", errObj.Message)
+		a.Equal(6, errObj.Pos.Column)
 	}
-
-	testIntegerObject(a, ao.Elements[0], 1)
-	testIntegerObject(a, ao.Elements[1], 4)
-	testIntegerObject(a, ao.Elements[2], 9)
 }
 
-func TestHashLiterals(t *testing.T) {
-	a := assert.New(t)
-	input := `let two = "two";
-	{
-		"one": 10 - 9,
-		two: 1 + 1,
-		"thr" + "ee": 6 / 2,
-		4: 4,
-		true: 5,
-		false: 6
-	}`
-
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
-	}
-
-	evaluated := testEval(a, input)
-	h, ok := evaluated.(*object.Hash)
-	if !a.True(ok) {
-		return
-	}
-
-	for expectedKey, expectedValue := range expected {
-		pair, ok := h.Pairs[expectedKey]
-		if !a.True(ok) {
-			continue
-		}
-		testIntegerObject(a, pair.Value, expectedValue)
-	}
-}
-
-func TestHashIndexExpression(t *testing.T) {
+func TestHashBuiltinErrors(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
-		input    string
-		expected interface{}
+		input           string
+		expectedMessage string
 	}{
-		{`{"foo": 5}["foo"]`, 5},
-		{`{"foo": 5}["bar"]`, nil},
-		{`let key = "foo"; {"foo": 5}[key]`, 5},
-		{`{}["foo"]`, nil},
-		{`{5: 5}[5]`, 5},
-		{`{true: 5}[true]`, 5},
-		{`{false: 5}[false]`, 5},
+		{`delete({}, fn(x) { x })`, "argument 2 to `delete` must be hashable, got FUNCTION"},
 	}
 
 	for _, tt := range tests {
-		evaulated := testEval(a, tt.input)
-		testObject(a, evaulated, tt.expected)
-	}
-}
-
-func TestStringConcatenation(t *testing.T) {
-	a := assert.New(t)
-	input := `"Hello" + " " + "World!"`
-	evaluated := testEval(a, input)
-	str, ok := evaluated.(*object.String)
-	if !a.True(ok) {
-		return
+		evaluated := testEval(a, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !a.True(ok, tt.input) {
+			continue
+		}
+		a.Equal(tt.expectedMessage, errObj.Message)
 	}
-
-	a.Equal(str.Value, "Hello World!")
 }
 
 func TestFunctionObject(t *testing.T) {
@@ -334,53 +306,14 @@ func TestFunctionObject(t *testing.T) {
 	a.Equal(fn.Body.String(), "(x + 2)")
 }
 
-func TestFunctionApplication(t *testing.T) {
-	a := assert.New(t)
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) {x; }(5)", 5},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(a, tt.input)
-		testObject(a, evaluated, tt.expected)
-	}
-}
-
-func TestExit(t *testing.T) {
-	a := assert.New(t)
-	tests := []struct {
-		input    string
-		expected int
-	}{
-		{"exit(0); 334;", 0},
-		{"264; exit(0); 334;", 0},
-		{"exit(227); 334;", 227},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(a, tt.input)
-		ex, ok := evaluated.(*object.Exit)
-		if !a.True(ok) {
-			continue
-		}
-		a.Equal(ex.Status, tt.expected)
-	}
-}
-
 func testObject(a *assert.Assertions, obj object.Object, expected interface{}) {
 	switch v := expected.(type) {
 	case int:
 		testIntegerObject(a, obj, int64(v))
 	case int64:
 		testIntegerObject(a, obj, v)
+	case float64:
+		testFloatObject(a, obj, v)
 	case bool:
 		testBooleanObject(a, obj, v)
 	case nil:
@@ -398,6 +331,14 @@ func testIntegerObject(a *assert.Assertions, obj object.Object, expected int64)
 	a.Equal(result.Value, expected)
 }
 
+func testFloatObject(a *assert.Assertions, obj object.Object, expected float64) {
+	result, ok := obj.(*object.Float)
+	if !a.True(ok) {
+		return
+	}
+	a.InDelta(expected, result.Value, 1e-9)
+}
+
 func testBooleanObject(a *assert.Assertions, obj object.Object, expected bool) {
 	result, ok := obj.(*object.Boolean)
 	if !a.True(ok) {
@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"sort"
+)
+
+// The functions in this file are shared with the other execution engines
+// (e.g. `vm`) so that all of them produce the same results.
+
+// Evaluate `<operator><right>`.
+func EvalPrefixExpression(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Evaluate `<left> <operator> <right>`.
+func EvalInfixExpression(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Evaluate `<left>[<index>]`.
+func EvalIndexExpression(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// Evaluate `<left>[<index>] = <val>`.
+func SetIndex(left, index, val object.Object) object.Object {
+	return setIndex(left, index, val, nil)
+}
+
+// Return the items which `for-in` visits in `iterable`.
+func Iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterate(iterable)
+}
+
+// Evaluate `import <path>` in the file `importer`, running the module by `run` unless `runtime` has imported it.
+func ImportModule(path object.Object, importer string, runtime *object.Runtime, run ModuleRunner) object.Object {
+	return importModule(path, importer, runtime, run)
+}
+
+// Collect the values of the bindings exported by the program of a module.
+// `lookUp` returns the value of a global binding.
+func ModuleExports(program *ast.Program, lookUp func(name string) object.Object) *object.Hash {
+	return moduleExports(program, lookUp)
+}
+
+// Return the error raised by `throw <val>`.
+func Throw(val object.Object) *object.Error {
+	return thrownError(val)
+}
+
+// Return the value bound to the parameter of `catch` for `errObj`.
+func CaughtValue(errObj *object.Error) object.Object {
+	return caughtValue(errObj)
+}
+
+// Concatenate the evaluated parts of an interpolated string.
+func Interpolate(parts []object.Object) object.Object {
+	return interpolate(parts)
+}
+
+// Evaluate `<left>[<start>:<end>]`. Missing bounds are `NULL`.
+func EvalSlice(left, start, end object.Object) object.Object {
+	return evalSlice(left, start, end)
+}
+
+// Check if the number of arguments `got` is from `min` to `max`. `max` is negative if unlimited.
+func CheckArity(got, min, max int) *object.Error {
+	return checkArity(got, min, max)
+}
+
+// Judge if `obj` is treated as true in conditions.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Return the names of all builtin functions in a stable order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return the builtin function named `name`.
+func LookUpBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// Call `fn` (e.g. a `Function` or a `Builtin`) with `args` from the host.
+// Functions are called under the limits of their interpreters.
+func CallFunction(fn object.Object, args ...object.Object) object.Object {
+	var limits *object.Limits
+	if function, ok := fn.(*object.Function); ok {
+		limits = function.Env.Limits()
+	}
+	return applyFunction(fn, args, limits)
+}
@@ -0,0 +1,114 @@
+package evaluator
+
+import "monkey/object"
+
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("keys", args, 1, 1, object.HASH); err != nil {
+				return err
+			}
+
+			pairs := args[0].(*object.Hash).OrderedPairs()
+			keys := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				keys[i] = pair.Key
+			}
+			return &object.Array{Elements: keys}
+		},
+	},
+	"values": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("values", args, 1, 1, object.HASH); err != nil {
+				return err
+			}
+
+			pairs := args[0].(*object.Hash).OrderedPairs()
+			values := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.Value
+			}
+			return &object.Array{Elements: values}
+		},
+	},
+	"items": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("items", args, 1, 1, object.HASH); err != nil {
+				return err
+			}
+
+			// Each item is an array of `[key, value]`
+			pairs := args[0].(*object.Hash).OrderedPairs()
+			items := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				items[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+			return &object.Array{Elements: items}
+		},
+	},
+	"has": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("has", args, 2, 2, object.HASH); err != nil {
+				return err
+			}
+			if err := checkHashable("has", args, 1); err != nil {
+				return err
+			}
+
+			_, ok := args[0].(*object.Hash).Get(args[1])
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	"delete": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("delete", args, 2, 2, object.HASH); err != nil {
+				return err
+			}
+			if err := checkHashable("delete", args, 1); err != nil {
+				return err
+			}
+
+			// Return the removed value, or null if the key is missing
+			hash := args[0].(*object.Hash)
+			if hash.Frozen {
+				return newError(object.TYPE_ERROR, "cannot modify frozen HASH")
+			}
+			value, ok := hash.Get(args[1])
+			if !ok {
+				return NULL
+			}
+			hash.Delete(args[1])
+			return value
+		},
+	},
+	"merge": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			// Later hashes take precedence; the arguments are not modified
+			merged := object.NewHash()
+			for i, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to `merge` must be HASH, got %s", i+1, arg.Kind())
+				}
+				for _, pair := range hash.OrderedPairs() {
+					merged.Set(pair.Key, pair.Value)
+				}
+			}
+			return merged
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range hashBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// Check if the `i`-th argument of the builtin `name` can be used as a hash key.
+func checkHashable(name string, args []object.Object, i int) *object.Error {
+	if object.IsHashable(args[i]) {
+		return nil
+	}
+	return newError(object.TYPE_ERROR, "argument %d to `%s` must be hashable, got %s", i+1, name, args[i].Kind())
+}
@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Run the program of a module and return the hash of its exports, or the error or the exit
+// which stopped it. The error is a failure which prevents running it (e.g. a compile error of `vm`).
+type ModuleRunner func(program *ast.Program) (object.Object, error)
+
+// Evaluate `import <path>`. `importer` is the file which contains the import expression.
+// The module is evaluated by the same interpreter (`runtime`) as the importer.
+// It returns a hash of the bindings exported by the module.
+func evalImport(path object.Object, importer string, runtime *object.Runtime) object.Object {
+	return importModule(path, importer, runtime, func(program *ast.Program) (object.Object, error) {
+		return runModule(program, runtime)
+	})
+}
+
+// Import the module at `path` by `run` unless `runtime` has imported it.
+// The cache of modules and the detection of import cycles are shared by all engines.
+func importModule(path object.Object, importer string, runtime *object.Runtime, run ModuleRunner) object.Object {
+	str, ok := path.(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "import path must be STRING, got %s", path.Kind())
+	}
+
+	filename := str.Value
+	if !filepath.IsAbs(filename) && importer != "" {
+		filename = filepath.Join(filepath.Dir(importer), filename)
+	}
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return newError(object.IMPORT_ERROR, "cannot import %q: %s", str.Value, err)
+	}
+
+	if module, ok := runtime.Modules[absPath]; ok {
+		return module
+	}
+	for i, loading := range runtime.ImportStack {
+		if loading == absPath {
+			cycle := append(append([]string{}, runtime.ImportStack[i:]...), absPath)
+			return newError(object.IMPORT_ERROR, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	runtime.ImportStack = append(runtime.ImportStack, absPath)
+	defer func() { runtime.ImportStack = runtime.ImportStack[:len(runtime.ImportStack)-1] }()
+
+	module := loadModule(filename, run)
+	if module, ok := module.(*object.Hash); ok {
+		runtime.Modules[absPath] = module
+	}
+	return module
+}
+
+// Parse the file and run it by `run`.
+func loadModule(filename string, run ModuleRunner) object.Object {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		return newError(object.IMPORT_ERROR, "cannot import %q: %s", filename, err)
+	}
+
+	l := lexer.NewFile(filename, string(input))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError(object.IMPORT_ERROR, "cannot import %q: %s", filename, p.Errors()[0])
+	}
+
+	module, err := run(program)
+	if err != nil {
+		return newError(object.IMPORT_ERROR, "cannot import %q: %s", filename, err)
+	}
+	return module
+}
+
+// Evaluate the program of a module in its own environment.
+func runModule(program *ast.Program, runtime *object.Runtime) (object.Object, error) {
+	env := object.NewEnvironmentWithRuntime(runtime)
+	result := Eval(program, env)
+	if isErrorOrExit(result) {
+		return result, nil
+	}
+
+	return moduleExports(program, func(name string) object.Object {
+		value, _ := env.Get(name)
+		return value
+	}), nil
+}
+
+// Collect the values of `export`ed bindings. `lookUp` returns the value of a global binding.
+func moduleExports(program *ast.Program, lookUp func(name string) object.Object) *object.Hash {
+	hash := object.NewHash()
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+
+		name := &object.String{Value: export.Statement.Name.Value}
+		value := lookUp(name.Value)
+		hash.Set(name, value)
+	}
+	return hash
+}
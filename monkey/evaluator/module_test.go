@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"lib/math.mk": `
+			let helper = fn(x) { x * 2 };
+			export let double = fn(x) { helper(x) };
+			export const answer = 42;`,
+		"lib/util.mk": `
+			let math = import "math.mk";
+			export let quadruple = fn(x) { math["double"](math["double"](x)) };`,
+		"main.mk": `
+			let math = import "lib/math.mk";
+			let util = import "lib/util.mk";
+			math["double"](util["quadruple"](1)) + math["answer"]`,
+	})
+
+	evaluated := evalFile(a, filepath.Join(dir, "main.mk"))
+	testObject(a, evaluated, 50)
+}
+
+func TestImportExports(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"lib.mk":  `let private = 1; export let public = 2;`,
+		"main.mk": `import "lib.mk"`,
+	})
+
+	evaluated := evalFile(a, filepath.Join(dir, "main.mk"))
+	h, ok := evaluated.(*object.Hash)
+	if !a.True(ok) {
+		return
+	}
+	a.Len(h.Pairs, 1)
+	pair, ok := h.Pairs[(&object.String{Value: "public"}).HashKey()]
+	if a.True(ok) {
+		testIntegerObject(a, pair.Value, 2)
+	}
+}
+
+func TestImportCache(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"counter.mk": `export let state = [0];`,
+		"main.mk": `
+			let first = import "counter.mk";
+			first["state"][0] = 5;
+			let second = import "./counter.mk";
+			second["state"][0]`,
+	})
+
+	evaluated := evalFile(a, filepath.Join(dir, "main.mk"))
+	testObject(a, evaluated, 5)
+}
+
+func TestImportErrors(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"a.mk":       `import "b.mk"`,
+		"b.mk":       `import "a.mk"`,
+		"broken.mk":  `let = 1;`,
+		"failing.mk": `1 + true`,
+		"cycle.mk":   `import "a.mk"`,
+		"missing.mk": `import "nothing.mk"`,
+		"badpath.mk": `import 1`,
+		"parse.mk":   `import "broken.mk"`,
+		"runtime.mk": `import "failing.mk"`,
+	})
+
+	tests := []struct {
+		file            string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{
+			"cycle.mk", object.IMPORT_ERROR,
+			"import cycle: " + filepath.Join(dir, "a.mk") + " -> " + filepath.Join(dir, "b.mk") + " -> " + filepath.Join(dir, "a.mk"),
+		},
+		{"badpath.mk", object.TYPE_ERROR, "import path must be STRING, got INTEGER"},
+		{"runtime.mk", object.TYPE_ERROR, "unknown operator: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := evalFile(a, filepath.Join(dir, tt.file)).(*object.Error)
+		if !a.True(ok, tt.file) {
+			continue
+		}
+		a.Equal(tt.expectedKind, errObj.ErrorKind)
+		a.Equal(tt.expectedMessage, errObj.Message)
+	}
+
+	for _, file := range []string{"missing.mk", "parse.mk"} {
+		errObj, ok := evalFile(a, filepath.Join(dir, file)).(*object.Error)
+		if a.True(ok, file) {
+			a.Equal(object.IMPORT_ERROR, errObj.ErrorKind)
+		}
+	}
+}
+
+func TestExportOutsideOfTopLevel(t *testing.T) {
+	a := assert.New(t)
+	p := parser.New(lexer.New("if (true) { export let x = 1; }"))
+	p.ParseProgram()
+	if a.NotEmpty(p.Errors()) {
+		a.Equal("1:13: export is only allowed at the top level", p.Errors()[0])
+	}
+}
+
+func writeFiles(a *assert.Assertions, dir string, files map[string]string) string {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		a.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		a.NoError(os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func evalFile(a *assert.Assertions, path string) object.Object {
+	input, err := os.ReadFile(path)
+	if !a.NoError(err) {
+		return nil
+	}
+
+	p := parser.New(lexer.NewFile(path, string(input)))
+	program := p.ParseProgram()
+	checkParserErrors(a, p)
+
+	return Eval(program, object.NewEnvironment())
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"context"
+	"math"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Approximate sizes in bytes of an element of an array and a pair of a hash
+const (
+	elementSize = 16
+	pairSize    = 64
+)
+
+// The number of iterations of loops in builtins counted as one evaluation step
+const iterationsPerStep = 1024
+
+// Limits of a sandboxed evaluation. Each of them is unlimited if zero.
+type Budget struct {
+	// The maximum number of evaluation steps (i.e. evaluated nodes)
+	MaxSteps int
+	// The approximate maximum number of bytes allocated for strings, arrays and hashes
+	MaxAllocs int
+}
+
+// Evaluate `node` in `env` within `budget`. The evaluation is aborted when `ctx` is done
+// or the budget runs out, and the result is an error which cannot be caught by `try`:
+// CANCELLED_ERROR, STEP_LIMIT_ERROR or MEMORY_LIMIT_ERROR respectively.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, budget Budget) object.Object {
+	limits := env.Limits()
+	saved := *limits
+	defer func() { *limits = saved }()
+
+	limits.Context = ctx
+	limits.MaxSteps, limits.Steps = budget.MaxSteps, 0
+	limits.MaxAllocs, limits.Allocs = budget.MaxAllocs, 0
+	return Eval(node, env)
+}
+
+// Count an evaluation step. It returns an error if the evaluation should be aborted.
+func step(limits *object.Limits) *object.Error {
+	if limits.Context != nil {
+		if err := limits.Context.Err(); err != nil {
+			return newError(object.CANCELLED_ERROR, "evaluation cancelled: %s", err)
+		}
+	}
+	if limits.MaxSteps > 0 {
+		if limits.Steps >= limits.MaxSteps {
+			return newError(object.STEP_LIMIT_ERROR, "step limit exceeded: %d steps", limits.MaxSteps)
+		}
+		limits.Steps++
+	}
+	return nil
+}
+
+// Check the limits in the `i`-th iteration of a loop in a builtin. The iterations
+// are counted as evaluation steps so that long loops can be aborted.
+func poll(limits *object.Limits, i int) *object.Error {
+	if limits == nil || i%iterationsPerStep != iterationsPerStep-1 {
+		return nil
+	}
+	return step(limits)
+}
+
+// Check if `size` bytes can be allocated before allocating them.
+// The allocation itself is counted by `charge` or `allocate` afterward.
+func reserve(limits *object.Limits, size int64) *object.Error {
+	if limits == nil || limits.MaxAllocs <= 0 {
+		return nil
+	}
+	if size > int64(limits.MaxAllocs-limits.Allocs) {
+		return newError(object.MEMORY_LIMIT_ERROR, "allocation limit exceeded: %d bytes", limits.MaxAllocs)
+	}
+	return nil
+}
+
+// Return the size of `count` elements of `size` bytes, saturating instead of overflowing.
+func sizeOf(count, size int64) int64 {
+	if size > 0 && count > math.MaxInt64/size {
+		return math.MaxInt64
+	}
+	return count * size
+}
+
+// Reserve the result of `<left> <operator> <right>` which may be much larger than the operands.
+func reserveInfix(limits *object.Limits, operator string, left, right object.Object) *object.Error {
+	switch {
+	case operator == "+" && left.Kind() == object.STRING && right.Kind() == object.STRING:
+		return reserve(limits, int64(len(left.(*object.String).Value))+int64(len(right.(*object.String).Value)))
+	case operator == "*" && isInteger(left) && isInteger(right):
+		return reserve(limits, int64(toBigInt(left).BitLen()+toBigInt(right).BitLen())/8)
+	case operator == "<<" && isInteger(left) && isInteger(right):
+		shift := toBigInt(right)
+		if !shift.IsInt64() {
+			// Rejected by the operator
+			return nil
+		}
+		return reserve(limits, (int64(toBigInt(left).BitLen())+shift.Int64())/8)
+	}
+	return nil
+}
+
+// Count `size` bytes of allocation. It returns an error if the budget runs out.
+func charge(limits *object.Limits, size int) *object.Error {
+	if limits == nil || limits.MaxAllocs <= 0 {
+		return nil
+	}
+	limits.Allocs += size
+	if limits.Allocs > limits.MaxAllocs {
+		return newError(object.MEMORY_LIMIT_ERROR, "allocation limit exceeded: %d bytes", limits.MaxAllocs)
+	}
+	return nil
+}
+
+// Count the allocation of a newly created `obj`. It returns `obj` or an error if the budget runs out.
+// Only the object itself is counted, not the objects it refers to.
+func allocate(limits *object.Limits, obj object.Object) object.Object {
+	var size int
+	switch obj := obj.(type) {
+	case *object.String:
+		size = len(obj.Value)
+	case *object.Array:
+		size = elementSize * len(obj.Elements)
+	case *object.Hash:
+		size = pairSize * len(obj.Pairs)
+	case *object.BigInteger:
+		size = (obj.Value.BitLen() + 7) / 8
+	default:
+		return obj
+	}
+	if err := charge(limits, size); err != nil {
+		return err
+	}
+	return obj
+}
+
+// Judge if `errObj` aborts a sandboxed evaluation.
+func isAborted(errObj *object.Error) bool {
+	switch errObj.ErrorKind {
+	case object.CANCELLED_ERROR, object.STEP_LIMIT_ERROR, object.MEMORY_LIMIT_ERROR:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalContextBudget(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input        string
+		budget       Budget
+		expectedKind object.ErrorKind
+	}{
+		{"while (true) {}", Budget{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"let f = fn() { f() }; f()", Budget{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"try { while (true) {} } catch (e) { 1 }", Budget{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"let xs = []; while (true) { xs = push(xs, 1) }", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{`let s = ""; while (true) { s += "abc" }`, Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"let f = fn(xs) { f(push(xs, 1)) }; try { f([]) } catch (e) { 1 }", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"range(0, 300000000)", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"range(0, 30000000)", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{`repeat("a", 2000000000)`, Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{`let s = "abcd"; while (true) { s = s + s }`, Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{`let s = "abcd"; while (true) { s = replace(s, "a", "aa") + "a" }`, Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{`let xs = ["abcd"]; while (true) { xs = push(xs, join(xs, "-")) }`, Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"let xs = [1]; while (true) { xs = concat(xs, xs) }", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"let xs = [1]; let i = 0; while (i < 64) { xs = [xs, xs]; i += 1 }; flatten(xs, 64)", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"let xs = range(100000); zip(xs, xs, xs)", Budget{MaxAllocs: 1 << 22}, object.MEMORY_LIMIT_ERROR},
+		{"let x = 1; while (true) { x = x << 1000000 }", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"let x = 3; while (true) { x = x * x }", Budget{MaxAllocs: 1 << 20}, object.MEMORY_LIMIT_ERROR},
+		{"range(0, 1000000)", Budget{MaxSteps: 100}, object.STEP_LIMIT_ERROR},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalContext(a, context.Background(), tt.input, tt.budget)
+		errObj, ok := evaluated.(*object.Error)
+		if a.True(ok, tt.input) {
+			a.Equal(tt.expectedKind, errObj.ErrorKind, tt.input)
+		}
+	}
+
+	evaluated := testEvalContext(a, context.Background(), "let f = fn(n) { n * 2 }; f(21)", Budget{MaxSteps: 1000, MaxAllocs: 1000})
+	testObject(a, evaluated, 42)
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	a := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	evaluated := testEvalContext(a, ctx, "while (true) {}", Budget{})
+	errObj, ok := evaluated.(*object.Error)
+	if a.True(ok) {
+		a.Equal(object.CANCELLED_ERROR, errObj.ErrorKind)
+		a.Equal("evaluation cancelled: context deadline exceeded", errObj.Message)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	evaluated = testEvalContext(a, ctx, "1", Budget{})
+	errObj, ok = evaluated.(*object.Error)
+	if a.True(ok) {
+		a.Equal(object.CANCELLED_ERROR, errObj.ErrorKind)
+	}
+}
+
+func TestEvalContextRestoresLimits(t *testing.T) {
+	a := assert.New(t)
+	env := object.NewEnvironment()
+
+	program := parser.New(lexer.New("let i = 0; while (i < 100) { i += 1 }; i")).ParseProgram()
+	evaluated := EvalContext(context.Background(), program, env, Budget{MaxSteps: 10})
+	errObj, ok := evaluated.(*object.Error)
+	if a.True(ok) {
+		a.Equal(object.STEP_LIMIT_ERROR, errObj.ErrorKind)
+	}
+
+	// The limits are lifted after `EvalContext`
+	testObject(a, Eval(program, env), 100)
+	a.Equal(object.Limits{MaxDepth: object.DefaultMaxDepth}, *env.Limits())
+}
+
+func testEvalContext(a *assert.Assertions, ctx context.Context, input string, budget Budget) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(a, p)
+
+	return EvalContext(ctx, program, object.NewEnvironment(), budget)
+}
@@ -0,0 +1,286 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"monkey/object"
+	"strings"
+	"unicode/utf8"
+)
+
+var stringBuiltins = map[string]*object.Builtin{
+	"split": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("split", args, 1, 2, object.STRING, object.STRING); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+
+			var parts []string
+			if len(args) == 1 {
+				// Split around runs of white spaces
+				parts = strings.Fields(str)
+			} else {
+				parts = strings.Split(str, args[1].(*object.String).Value)
+			}
+			return stringsToArray(parts)
+		},
+	},
+	"join": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			if err := checkArgs("join", args, 1, 2, object.ARRAY, object.STRING); err != nil {
+				return err
+			}
+
+			parts := []string{}
+			for _, el := range args[0].(*object.Array).Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "elements of argument to `join` must be STRING, got %s", el.Kind())
+				}
+				parts = append(parts, str.Value)
+			}
+
+			sep := ""
+			if len(args) == 2 {
+				sep = args[1].(*object.String).Value
+			}
+			size := sizeOf(int64(len(parts)), int64(len(sep)+1))
+			for _, part := range parts {
+				size += int64(len(part))
+			}
+			if err := reserve(limits, size); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	"trim": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("trim", args, 1, 2, object.STRING, object.STRING); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(str)}
+			}
+			// The second argument is the set of characters to be removed
+			return &object.String{Value: strings.Trim(str, args[1].(*object.String).Value)}
+		},
+	},
+	"replace": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			if err := checkArgs("replace", args, 3, 3, object.STRING, object.STRING, object.STRING); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+			old := args[1].(*object.String).Value
+			replacement := args[2].(*object.String).Value
+			count := int64(strings.Count(str, old))
+			if err := reserve(limits, int64(len(str))+sizeOf(count, int64(len(replacement)+1))); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ReplaceAll(str, old, replacement)}
+		},
+	},
+	"contains":    stringPredicate("contains", strings.Contains),
+	"starts_with": stringPredicate("starts_with", strings.HasPrefix),
+	"ends_with":   stringPredicate("ends_with", strings.HasSuffix),
+	"upper":       stringConversion("upper", strings.ToUpper),
+	"lower":       stringConversion("lower", strings.ToLower),
+	"repeat": &object.Builtin{
+		LimitedFn: func(limits *object.Limits, args ...object.Object) object.Object {
+			if err := checkArgs("repeat", args, 2, 2, object.STRING, object.INTEGER); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+			count := clampInteger(args[1])
+			if count < 0 {
+				return newError(object.VALUE_ERROR, "negative repeat count: %s", args[1].Inspect())
+			}
+			if err := reserve(limits, sizeOf(count, int64(len(str)))); err != nil {
+				return err
+			}
+			if len(str) > 0 && count > math.MaxInt32/int64(len(str)) {
+				return newError(object.VALUE_ERROR, "repeat count too large: %s", args[1].Inspect())
+			}
+			return &object.String{Value: strings.Repeat(str, int(count))}
+		},
+	},
+	"index_of": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("index_of", args, 2, 2, object.STRING, object.STRING); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+
+			index := strings.Index(str, args[1].(*object.String).Value)
+			if index < 0 {
+				return &object.Integer{Value: -1}
+			}
+			// Count in runes to be consistent with indexing
+			return &object.Integer{Value: int64(utf8.RuneCountInString(str[:index]))}
+		},
+	},
+	"format": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(len(args), 1, -1); err != nil {
+				return err
+			}
+			if err := checkArgs("format", args[:1], 1, 1, object.STRING); err != nil {
+				return err
+			}
+
+			format := args[0].(*object.String).Value
+			values, err := formatValues(format, args[1:])
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: fmt.Sprintf(format, values...)}
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// Create a builtin which tests `fn(str, substr)`.
+func stringPredicate(name string, fn func(string, string) bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs(name, args, 2, 2, object.STRING, object.STRING); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(fn(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	}
+}
+
+// Create a builtin which converts a string by `fn`.
+func stringConversion(name string, fn func(string) string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs(name, args, 1, 1, object.STRING); err != nil {
+				return err
+			}
+			return &object.String{Value: fn(args[0].(*object.String).Value)}
+		},
+	}
+}
+
+// The kinds of arguments accepted by each verb of `format`. `%s` and `%v` accept any kind.
+var formatVerbKinds = map[rune][]object.ObjectKind{
+	'd': {object.INTEGER, object.BIG_INTEGER},
+	'b': {object.INTEGER, object.BIG_INTEGER},
+	'o': {object.INTEGER, object.BIG_INTEGER},
+	'x': {object.INTEGER, object.BIG_INTEGER},
+	'X': {object.INTEGER, object.BIG_INTEGER},
+	'e': {object.FLOAT, object.INTEGER},
+	'E': {object.FLOAT, object.INTEGER},
+	'f': {object.FLOAT, object.INTEGER},
+	'F': {object.FLOAT, object.INTEGER},
+	'g': {object.FLOAT, object.INTEGER},
+	'G': {object.FLOAT, object.INTEGER},
+	't': {object.BOOLEAN},
+	'q': {object.STRING},
+	's': nil,
+	'v': nil,
+}
+
+// Check the verbs of `format` against `args` and convert the arguments into Go values for `fmt.Sprintf`.
+// A verb which does not accept its argument is a TYPE_ERROR, and an unknown verb or
+// a mismatched number of arguments is a VALUE_ERROR.
+func formatValues(format string, args []object.Object) ([]interface{}, *object.Error) {
+	values := []interface{}{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		// Skip the flags, the width and the precision
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return nil, newError(object.VALUE_ERROR, "incomplete verb at the end of the format string")
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if verb == '%' {
+			continue
+		}
+
+		kinds, ok := formatVerbKinds[verb]
+		if !ok {
+			return nil, newError(object.VALUE_ERROR, "unknown verb in the format string: %%%c", verb)
+		}
+		if len(values) == len(args) {
+			return nil, newError(object.VALUE_ERROR, "missing argument for %%%c in the format string", verb)
+		}
+		arg := args[len(values)]
+		if kinds != nil && !isKindOf(arg, kinds) {
+			return nil, newError(object.TYPE_ERROR, "argument %d to `format` for %%%c must be %s, got %s",
+				len(values)+2, verb, joinKinds(kinds), arg.Kind())
+		}
+		values = append(values, formatValue(arg, verb))
+	}
+
+	if len(values) != len(args) {
+		return nil, newError(object.VALUE_ERROR, "too many arguments to `format`: got=%d, want=%d", len(args), len(values))
+	}
+	return values, nil
+}
+
+func isKindOf(obj object.Object, kinds []object.ObjectKind) bool {
+	for _, kind := range kinds {
+		if obj.Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Join `kinds` for error messages (e.g. "INTEGER or BIG_INTEGER").
+func joinKinds(kinds []object.ObjectKind) string {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = kind.String()
+	}
+	return strings.Join(names, " or ")
+}
+
+// Convert an object into a Go value for `verb` of `fmt` (e.g. `%d`, `%5.2f`, `%s`).
+func formatValue(obj object.Object, verb rune) interface{} {
+	if _, ok := obj.(*object.String); verb == 's' && !ok {
+		return obj.Inspect()
+	}
+	switch obj := obj.(type) {
+	case *object.Integer:
+		if strings.ContainsRune("eEfFgG", verb) {
+			return float64(obj.Value)
+		}
+		return obj.Value
+	case *object.BigInteger:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	default:
+		return obj.Inspect()
+	}
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Evaluate the body of a function. Calls in tail position (the last expression
+// of the body, including the branches of a trailing `if`, and `return f(x)`)
+// are not performed but returned as `TailCall`s, which `applyFunction` performs
+// in a loop instead of recursion.
+func evalFunctionBody(body *ast.BlockStatement, env *object.Environment) object.Object {
+	return evalTailStatements(body.Statements, env, true)
+}
+
+// `last` is true if the value of the statements is the result of the function.
+func evalTailStatements(stmts []ast.Statement, env *object.Environment, last bool) object.Object {
+	var result object.Object
+
+	for i, statement := range stmts {
+		result = evalTailStatement(statement, env, last && i == len(stmts)-1)
+
+		switch result := result.(type) {
+		case *object.ReturnValue, *object.Error, *object.Exit, *object.TailCall:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalTailStatement(stmt ast.Statement, env *object.Environment, last bool) object.Object {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		if call, ok := stmt.ReturnValue.(*ast.CallExpression); ok {
+			// The result of the call is returned as is, so it is always in tail position
+			tailCall := evalTailCall(call, env)
+			if isErrorOrExit(tailCall) {
+				return tailCall
+			}
+			return &object.ReturnValue{Value: tailCall}
+		}
+	case *ast.ExpressionStatement:
+		switch exp := stmt.Expression.(type) {
+		case *ast.IfExpression:
+			return evalTailIfExpression(exp, env, last)
+		case *ast.CallExpression:
+			if last {
+				return evalTailCall(exp, env)
+			}
+		}
+	}
+	return Eval(stmt, env)
+}
+
+func evalTailIfExpression(ie *ast.IfExpression, env *object.Environment, last bool) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isErrorOrExit(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return evalTailStatements(ie.Consequence.Statements, env, last)
+	} else if ie.Alternative != nil {
+		return evalTailStatements(ie.Alternative.Statements, env, last)
+	} else {
+		return NULL
+	}
+}
+
+// Evaluate the function and the arguments of `call` without calling it.
+func evalTailCall(call *ast.CallExpression, env *object.Environment) object.Object {
+	function := Eval(call.Function, env)
+	if isErrorOrExit(function) {
+		return function
+	}
+	args, err := evalArguments(call.Arguments, env)
+	if err != nil {
+		return err
+	}
+	return &object.TailCall{Function: function, Arguments: args, Call: call}
+}
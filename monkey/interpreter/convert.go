@@ -0,0 +1,607 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// Convert the Go value `value` into a Monkey object.
+//
+//   - nil and nil pointers become `null`
+//   - integers become INTEGER (or BIG_INTEGER if out of int64 range), including `*big.Int`
+//   - floats, strings and bools become FLOAT, STRING and BOOLEAN
+//   - slices and arrays become ARRAY
+//   - maps become HASH whose keys are sorted by their representations
+//   - structs become HASH of their exported fields in order. The key of a field is its name
+//     or the name given by the tag `monkey:"name"`. Fields tagged `monkey:"-"` are omitted.
+//   - funcs become builtins which convert their arguments by `FromObject`. A trailing
+//     `error` result is raised as a RuntimeError if it is not nil, and multiple results
+//     are returned as an ARRAY.
+//   - `object.Object` values are returned as is
+//
+// Pointers and interfaces are converted into the values they refer to.
+// A value which contains itself (e.g. a pointer to a struct pointing back to it) is an error.
+func ToObject(value interface{}) (object.Object, error) {
+	return toObject(reflect.ValueOf(value), "", map[reference]bool{})
+}
+
+// A pointer, map or slice being converted, to detect cycles.
+// The length distinguishes a slice from its subslices which share the same array.
+type reference struct {
+	ptr    uintptr
+	length int
+	typ    reflect.Type
+}
+
+// Convert `v` at `path`. `inProgress` holds the references being converted.
+func toObject(v reflect.Value, path string, inProgress map[reference]bool) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return integerObject(v.Interface().(*big.Int)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		ref := reference{ptr: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			ref.length = v.Len()
+		}
+		if inProgress[ref] {
+			return nil, conversionError(path, "cannot convert a cyclic %s", v.Type())
+		}
+		inProgress[ref] = true
+		defer delete(inProgress, ref)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return toObject(v.Elem(), path, inProgress)
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return integerObject(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i), fmt.Sprintf("%s[%d]", path, i), inProgress)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return mapToObject(v, path, inProgress)
+	case reflect.Struct:
+		return structToObject(v, path, inProgress)
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return funcToObject(v), nil
+	default:
+		return nil, conversionError(path, "cannot convert %s to a Monkey object", v.Type())
+	}
+}
+
+func mapToObject(v reflect.Value, path string, inProgress map[reference]bool) (object.Object, error) {
+	pairs := make([]object.HashPair, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		keyPath := fmt.Sprintf("%s[%v]", path, iter.Key())
+		key, err := toObject(iter.Key(), keyPath, inProgress)
+		if err != nil {
+			return nil, err
+		}
+		if !object.IsHashable(key) {
+			return nil, conversionError(keyPath, "unusable as hash key: %s", key.Kind())
+		}
+		value, err := toObject(iter.Value(), keyPath, inProgress)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, object.HashPair{Key: key, Value: value})
+	}
+
+	// Go maps are not ordered, so the order is fixed for reproducible results
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+	hash := object.NewHash()
+	for _, pair := range pairs {
+		hash.Set(pair.Key, pair.Value)
+	}
+	return hash, nil
+}
+
+func structToObject(v reflect.Value, path string, inProgress map[reference]bool) (object.Object, error) {
+	hash := object.NewHash()
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+		value, err := toObject(v.Field(i), path+"."+name, inProgress)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(&object.String{Value: name}, value)
+	}
+	return hash, nil
+}
+
+// Return the key of a struct field in hashes. It is false if the field is not converted.
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		// Unexported
+		return "", false
+	}
+	switch tag := field.Tag.Get("monkey"); tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// Wrap a Go func into a builtin.
+func funcToObject(fn reflect.Value) object.Object {
+	fnType := fn.Type()
+	numIn, max := fnType.NumIn(), fnType.NumIn()
+	if fnType.IsVariadic() {
+		numIn, max = numIn-1, -1
+	}
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := evaluator.CheckArity(len(args), numIn, max); err != nil {
+				return err
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				var paramType reflect.Type
+				if fnType.IsVariadic() && i >= numIn {
+					paramType = fnType.In(numIn).Elem()
+				} else {
+					paramType = fnType.In(i)
+				}
+				in[i] = reflect.New(paramType).Elem()
+				if err := fromObject(arg, in[i], ""); err != nil {
+					return &object.Error{ErrorKind: object.TYPE_ERROR, Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+				}
+			}
+
+			out := fn.Call(in)
+			if n := len(out); n > 0 && fnType.Out(n-1) == errorType {
+				if err, _ := out[n-1].Interface().(error); err != nil {
+					return &object.Error{ErrorKind: object.RUNTIME_ERROR, Message: err.Error()}
+				}
+				out = out[:n-1]
+			}
+
+			switch len(out) {
+			case 0:
+				return evaluator.NULL
+			case 1:
+				return resultObject(out[0])
+			default:
+				elements := make([]object.Object, len(out))
+				for i, value := range out {
+					elements[i] = resultObject(value)
+					if errObj, ok := elements[i].(*object.Error); ok {
+						return errObj
+					}
+				}
+				return &object.Array{Elements: elements}
+			}
+		},
+	}
+}
+
+// Convert a result of a Go func into a Monkey object, or an error if it is not convertible.
+func resultObject(v reflect.Value) object.Object {
+	obj, err := toObject(v, "", map[reference]bool{})
+	if err != nil {
+		return &object.Error{ErrorKind: object.TYPE_ERROR, Message: err.Error()}
+	}
+	return obj
+}
+
+// Store the Monkey object `obj` into the Go value pointed to by `target` (e.g. `&x`).
+// The conversion is the inverse of `ToObject`:
+//
+//   - INTEGER and BIG_INTEGER are stored into integers (checking overflow), floats and `*big.Int`
+//   - FLOAT is stored into floats, STRING into strings and BOOLEAN into bools
+//   - ARRAY is stored into slices and arrays of the same length
+//   - HASH is stored into maps and structs. Missing fields are left as they are and keys
+//     which do not match any field are ignored.
+//   - functions are stored into funcs which call them, converting the arguments by `ToObject`
+//     and the result by `FromObject`. The func must have a trailing `error` result,
+//     which returns Monkey errors and conversion errors.
+//   - `null` is stored as the zero value of pointers, slices, maps, funcs and interfaces
+//
+// An empty interface receives int64, *big.Int, float64, string, bool, nil, []interface{} or
+// map[string]interface{} (map[interface{}]interface{} if a key is not a string).
+// Functions are stored as is.
+func FromObject(obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	if obj == nil {
+		return errors.New("cannot convert nil object")
+	}
+	return fromObject(obj, v.Elem(), "")
+}
+
+func fromObject(obj object.Object, v reflect.Value, path string) error {
+	if v.Type() == bigIntType {
+		return setBigInt(obj, v, path)
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		value, err := naturalValue(obj, path)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Kind() == object.NULL {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := fromObject(obj, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integ, ok := obj.(*object.Integer); ok {
+			if v.OverflowInt(integ.Value) {
+				return conversionError(path, "%d overflows %s", integ.Value, v.Type())
+			}
+			v.SetInt(integ.Value)
+			return nil
+		}
+		if obj.Kind() == object.BIG_INTEGER {
+			return conversionError(path, "%s overflows %s", obj.Inspect(), v.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		value, ok := bigIntValue(obj)
+		if ok {
+			if value.Sign() < 0 || !value.IsUint64() || v.OverflowUint(value.Uint64()) {
+				return conversionError(path, "%s overflows %s", obj.Inspect(), v.Type())
+			}
+			v.SetUint(value.Uint64())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch obj := obj.(type) {
+		case *object.Float:
+			if v.Kind() == reflect.Float32 && !math.IsInf(obj.Value, 0) && v.OverflowFloat(obj.Value) {
+				return conversionError(path, "%s overflows %s", obj.Inspect(), v.Type())
+			}
+			v.SetFloat(obj.Value)
+			return nil
+		case *object.Integer:
+			v.SetFloat(float64(obj.Value))
+			return nil
+		case *object.BigInteger:
+			value, _ := new(big.Float).SetInt(obj.Value).Float64()
+			v.SetFloat(value)
+			return nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*object.String); ok {
+			v.SetString(str.Value)
+			return nil
+		}
+	case reflect.Slice:
+		if array, ok := obj.(*object.Array); ok {
+			slice := reflect.MakeSlice(v.Type(), len(array.Elements), len(array.Elements))
+			for i, element := range array.Elements {
+				if err := fromObject(element, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if array, ok := obj.(*object.Array); ok {
+			if len(array.Elements) != v.Len() {
+				return conversionError(path, "cannot convert ARRAY of length %d to %s", len(array.Elements), v.Type())
+			}
+			for i, element := range array.Elements {
+				if err := fromObject(element, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			m := reflect.MakeMapWithSize(v.Type(), len(hash.Pairs))
+			for _, pair := range hash.OrderedPairs() {
+				keyPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := fromObject(pair.Key, key, keyPath); err != nil {
+					return err
+				}
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := fromObject(pair.Value, value, keyPath); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*object.Hash); ok {
+			for i := 0; i < v.NumField(); i++ {
+				name, ok := fieldName(v.Type().Field(i))
+				if !ok {
+					continue
+				}
+				value, ok := hash.Get(&object.String{Value: name})
+				if !ok {
+					continue
+				}
+				if err := fromObject(value, v.Field(i), path+"."+name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Func:
+		switch obj.(type) {
+		case *object.Function, *object.Builtin:
+			fnType := v.Type()
+			if fnType.NumOut() == 0 || fnType.Out(fnType.NumOut()-1) != errorType {
+				return conversionError(path, "cannot convert %s to %s without a trailing error result", obj.Kind(), fnType)
+			}
+			v.Set(objectToFunc(obj, fnType))
+			return nil
+		}
+	}
+
+	return conversionError(path, "cannot convert %s to %s", obj.Kind(), v.Type())
+}
+
+func setBigInt(obj object.Object, v reflect.Value, path string) error {
+	if obj.Kind() == object.NULL {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	value, ok := bigIntValue(obj)
+	if !ok {
+		return conversionError(path, "cannot convert %s to %s", obj.Kind(), v.Type())
+	}
+	v.Set(reflect.ValueOf(value))
+	return nil
+}
+
+// Return the value of an integer object as a new `big.Int`.
+func bigIntValue(obj object.Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value), true
+	case *object.BigInteger:
+		return new(big.Int).Set(obj.Value), true
+	default:
+		return nil, false
+	}
+}
+
+// Return `obj` as the Go value of the most natural type, which is stored into an empty interface.
+func naturalValue(obj object.Object, path string) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInteger:
+		return new(big.Int).Set(obj.Value), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := naturalValue(element, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *object.Hash:
+		return naturalMap(obj, path)
+	default:
+		return obj, nil
+	}
+}
+
+func naturalMap(hash *object.Hash, path string) (interface{}, error) {
+	stringKeys := true
+	for _, pair := range hash.OrderedPairs() {
+		if pair.Key.Kind() != object.STRING {
+			stringKeys = false
+		}
+	}
+
+	if stringKeys {
+		m := make(map[string]interface{}, len(hash.Pairs))
+		for _, pair := range hash.OrderedPairs() {
+			key := pair.Key.(*object.String).Value
+			value, err := naturalValue(pair.Value, fmt.Sprintf("%s[%s]", path, strconv.Quote(key)))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+
+	m := make(map[interface{}]interface{}, len(hash.Pairs))
+	for _, pair := range hash.OrderedPairs() {
+		keyPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+		key, err := naturalValue(pair.Key, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return nil, conversionError(keyPath, "cannot use %s as a key of a Go map", pair.Key.Kind())
+		}
+		value, err := naturalValue(pair.Value, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// Wrap a Monkey function into a Go func of `fnType`, whose last result is `error`.
+func objectToFunc(fn object.Object, fnType reflect.Type) reflect.Value {
+	return reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+		if fnType.IsVariadic() {
+			last := in[len(in)-1]
+			in = in[:len(in)-1]
+			for i := 0; i < last.Len(); i++ {
+				in = append(in, last.Index(i))
+			}
+		}
+
+		out := make([]reflect.Value, fnType.NumOut())
+		for i := range out {
+			out[i] = reflect.New(fnType.Out(i)).Elem()
+		}
+		fail := func(err error) []reflect.Value {
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+
+		args := make([]object.Object, len(in))
+		for i, value := range in {
+			arg, err := toObject(value, "", map[reference]bool{})
+			if err != nil {
+				return fail(fmt.Errorf("argument %d: %w", i+1, err))
+			}
+			args[i] = arg
+		}
+
+		result := evaluator.CallFunction(fn, args...)
+		if errObj, ok := result.(*object.Error); ok {
+			return fail(errObj)
+		}
+
+		results := out[:len(out)-1]
+		switch len(results) {
+		case 0:
+		case 1:
+			if err := fromObject(result, results[0], ""); err != nil {
+				return fail(err)
+			}
+		default:
+			array, ok := result.(*object.Array)
+			if !ok || len(array.Elements) != len(results) {
+				return fail(fmt.Errorf("cannot convert %s to %d results", result.Kind(), len(results)))
+			}
+			for i, element := range array.Elements {
+				if err := fromObject(element, results[i], fmt.Sprintf("[%d]", i)); err != nil {
+					return fail(err)
+				}
+			}
+		}
+		return out
+	})
+}
+
+func conversionError(path, format string, a ...interface{}) error {
+	message := fmt.Sprintf(format, a...)
+	if path != "" {
+		message += " at " + path
+	}
+	return errors.New(message)
+}
+
+// Return an integer object of `value`, which is demoted to INTEGER if it fits.
+func integerObject(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInteger{Value: value}
+}
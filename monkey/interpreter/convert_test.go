@@ -0,0 +1,249 @@
+package interpreter
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"monkey/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `monkey:"city"`
+	Zip  string `monkey:"-"`
+}
+
+type person struct {
+	Name    string `monkey:"name"`
+	Age     int    `monkey:"age"`
+	Tags    []string
+	Address *address `monkey:"address"`
+	secret  string
+}
+
+func TestToObject(t *testing.T) {
+	a := assert.New(t)
+	var nilMap map[string]int
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{big.NewInt(7), "7"},
+		{1.5, "1.5"},
+		{"hi", "hi"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{nilMap, "null"},
+		{[]interface{}{1, "x", nil, []bool{false}}, "[1, x, null, [false]]"},
+		{
+			person{Name: "Ann", Age: 30, Tags: []string{"x"}, Address: &address{City: "Oslo", Zip: "0150"}, secret: "s"},
+			"{name: Ann, age: 30, Tags: [x], address: {city: Oslo}}",
+		},
+		{&person{Name: "Bob"}, "{name: Bob, age: 0, Tags: null, address: null}"},
+		{&object.Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if a.NoError(err, "%v", tt.input) {
+			a.Equal(tt.expected, obj.Inspect())
+		}
+	}
+
+	_, err := ToObject(make(chan int))
+	a.EqualError(err, "cannot convert chan int to a Monkey object")
+	_, err = ToObject(map[string]interface{}{"c": complex(1, 2)})
+	a.EqualError(err, "cannot convert complex128 to a Monkey object at [c]")
+
+	type node struct {
+		Next *node
+	}
+	cyclicNode := &node{}
+	cyclicNode.Next = cyclicNode
+	_, err = ToObject(cyclicNode)
+	a.EqualError(err, "cannot convert a cyclic *interpreter.node at .Next")
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["self"] = cyclicMap
+	_, err = ToObject(cyclicMap)
+	a.EqualError(err, "cannot convert a cyclic map[string]interface {} at [self]")
+	cyclicSlice := []interface{}{nil}
+	cyclicSlice[0] = cyclicSlice
+	_, err = ToObject(cyclicSlice)
+	a.EqualError(err, "cannot convert a cyclic []interface {} at [0]")
+
+	// Shared values are not cycles
+	shared := &address{City: "Oslo"}
+	obj, err := ToObject([]*address{shared, shared})
+	if a.NoError(err) {
+		a.Equal("[{city: Oslo}, {city: Oslo}]", obj.Inspect())
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	eval := func(src string) object.Object {
+		obj, err := in.Eval(src)
+		a.NoError(err, src)
+		return obj
+	}
+
+	var n int
+	a.NoError(FromObject(eval("40 + 2"), &n))
+	a.Equal(42, n)
+
+	var f float64
+	a.NoError(FromObject(eval("3"), &f))
+	a.Equal(3.0, f)
+
+	var b *big.Int
+	a.NoError(FromObject(eval("1180591620717411303424"), &b))
+	a.Equal("1180591620717411303424", b.String())
+
+	var xs []string
+	a.NoError(FromObject(eval(`["a", "b"]`), &xs))
+	a.Equal([]string{"a", "b"}, xs)
+
+	var m map[string]int
+	a.NoError(FromObject(eval(`{"a": 1, "b": 2}`), &m))
+	a.Equal(map[string]int{"a": 1, "b": 2}, m)
+
+	var p person
+	a.NoError(FromObject(eval(`{"name": "Ann", "age": 30, "Tags": ["x"], "address": {"city": "Oslo"}, "extra": 1}`), &p))
+	a.Equal(person{Name: "Ann", Age: 30, Tags: []string{"x"}, Address: &address{City: "Oslo"}}, p)
+
+	var value interface{}
+	a.NoError(FromObject(eval(`{"a": [1, 2.5, first([]), true]}`), &value))
+	a.Equal(map[string]interface{}{"a": []interface{}{int64(1), 2.5, nil, true}}, value)
+	a.NoError(FromObject(eval(`{1: "one"}`), &value))
+	a.Equal(map[interface{}]interface{}{int64(1): "one"}, value)
+
+	var obj object.Object
+	a.NoError(FromObject(eval("[1]"), &obj))
+	a.Equal("[1]", obj.Inspect())
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	tests := []struct {
+		input    string
+		target   interface{}
+		expected string
+	}{
+		{`"x"`, new(int), "cannot convert STRING to int"},
+		{"300", new(int8), "300 overflows int8"},
+		{"-1", new(uint), "-1 overflows uint"},
+		{"1180591620717411303424", new(int64), "1180591620717411303424 overflows int64"},
+		{"[1, 2]", new([3]int), "cannot convert ARRAY of length 2 to [3]int"},
+		{`[1, "x"]`, new([]int), "cannot convert STRING to int at [1]"},
+		{`{"age": "old"}`, new(person), "cannot convert STRING to int at .age"},
+		{`{[1]: 1}`, new(interface{}), "cannot use ARRAY as a key of a Go map at [[1]]"},
+	}
+
+	for _, tt := range tests {
+		obj, err := in.Eval(tt.input)
+		if !a.NoError(err) {
+			continue
+		}
+		a.EqualError(FromObject(obj, tt.target), tt.expected, tt.input)
+	}
+
+	var n int
+	a.EqualError(FromObject(&object.Integer{Value: 1}, n), "target must be a non-nil pointer, got int")
+}
+
+func TestGoFunctions(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	set := func(name string, value interface{}) {
+		obj, err := ToObject(value)
+		if a.NoError(err) {
+			in.Set(name, obj)
+		}
+	}
+	set("upper", strings.ToUpper)
+	set("sum", func(xs ...int) int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	})
+	set("divmod", func(x, y int) (int, int, error) {
+		if y == 0 {
+			return 0, 0, errors.New("division by zero")
+		}
+		return x / y, x % y, nil
+	})
+
+	result, err := in.Eval(`[upper("abc"), sum(), sum(1, 2, 3), divmod(7, 2)]`)
+	if a.NoError(err) {
+		a.Equal("[ABC, 0, 6, [3, 1]]", result.Inspect())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"divmod(1, 0)", "1:7: RuntimeError: division by zero"},
+		{`try { divmod(1, 0) } catch (e) { e["message"] }`, ""},
+		{"upper(1)", "1:6: TypeError: argument 1: cannot convert INTEGER to string"},
+		{"upper()", "1:6: ArgumentError: wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		result, err := in.Eval(tt.input)
+		if tt.expected == "" {
+			if a.NoError(err) {
+				a.Equal("division by zero", result.Inspect())
+			}
+			continue
+		}
+		a.EqualError(err, tt.expected, tt.input)
+	}
+}
+
+func TestMonkeyFunctions(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	obj, err := in.Eval("fn(x, y) { if (y == 0) { throw \"zero\" } x / y }")
+	if !a.NoError(err) {
+		return
+	}
+
+	var div func(int, int) (int, error)
+	a.NoError(FromObject(obj, &div))
+	q, err := div(7, 2)
+	a.NoError(err)
+	a.Equal(3, q)
+	_, err = div(1, 0)
+	a.EqualError(err, "1:26: UserError: zero")
+
+	var mustDiv func(int, int) int
+	a.EqualError(FromObject(obj, &mustDiv), "cannot convert FUNCTION to func(int, int) int without a trailing error result")
+	a.Nil(mustDiv)
+
+	obj, err = in.Eval(`fn(...xs) { len(xs) }`)
+	if !a.NoError(err) {
+		return
+	}
+	var count func(...string) (int, error)
+	a.NoError(FromObject(obj, &count))
+	n, err := count("a", "b", "c")
+	a.NoError(err)
+	a.Equal(3, n)
+}
@@ -0,0 +1,93 @@
+// Package interpreter provides an API to embed Monkey in Go programs.
+package interpreter
+
+import (
+	"context"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"strings"
+)
+
+// An isolated Monkey interpreter. Its global variables, registered functions,
+// imported modules and limits are not shared with other interpreters.
+// An interpreter must not be used by multiple goroutines at the same time.
+type Interpreter struct {
+	env *object.Environment
+}
+
+// Create an interpreter with an empty global scope.
+func New() *Interpreter {
+	return &Interpreter{env: object.NewEnvironment()}
+}
+
+// Errors detected while parsing a script
+type ParseError struct {
+	Errors []*parser.Error
+}
+
+func (pe *ParseError) Error() string {
+	messages := make([]string, len(pe.Errors))
+	for i, err := range pe.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Evaluate `src` in the global scope and return the value of the last statement.
+// The error is a `*ParseError` or an `*object.Error` raised by the script.
+// `exit(status)` results in an `*object.Exit`.
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	return in.EvalContext(context.Background(), src, evaluator.Budget{})
+}
+
+// Evaluate the script at `path` like `Eval`. Relative imports are resolved from its directory.
+func (in *Interpreter) EvalFile(path string) (object.Object, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return in.eval(context.Background(), lexer.NewFile(path, string(input)), evaluator.Budget{})
+}
+
+// Evaluate `src` like `Eval` within `budget` until `ctx` is done. See `evaluator.EvalContext`.
+func (in *Interpreter) EvalContext(ctx context.Context, src string, budget evaluator.Budget) (object.Object, error) {
+	return in.eval(ctx, lexer.New(src), budget)
+}
+
+func (in *Interpreter) eval(ctx context.Context, l *lexer.Lexer, budget evaluator.Budget) (object.Object, error) {
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.DetailedErrors()) != 0 {
+		return nil, &ParseError{Errors: p.DetailedErrors()}
+	}
+
+	result := evaluator.EvalContext(ctx, program, in.env, budget)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// Bind `value` to the global variable `name`.
+func (in *Interpreter) Set(name string, value object.Object) {
+	in.env.Set(name, value)
+}
+
+// Return the value of the global variable `name`.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	return in.env.Get(name)
+}
+
+// Register `fn` as the builtin `name` of this interpreter. It takes precedence over
+// the standard builtin of the same name, and is also available in imported modules.
+func (in *Interpreter) RegisterFunc(name string, fn object.BuiltinFunction) {
+	in.env.Runtime().Builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// Return the limits of this interpreter, e.g. to configure `MaxDepth`.
+func (in *Interpreter) Limits() *object.Limits {
+	return in.env.Limits()
+}
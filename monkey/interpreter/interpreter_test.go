@@ -0,0 +1,132 @@
+package interpreter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monkey/evaluator"
+	"monkey/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	result, err := in.Eval("let double = fn(x) { x * 2 }; double(21)")
+	if a.NoError(err) {
+		a.Equal("42", result.Inspect())
+	}
+
+	// The global scope is kept between evaluations
+	result, err = in.Eval("double(5)")
+	if a.NoError(err) {
+		a.Equal("10", result.Inspect())
+	}
+
+	_, err = in.Eval("1 + true")
+	errObj, ok := err.(*object.Error)
+	if a.True(ok) {
+		a.Equal(object.TYPE_ERROR, errObj.ErrorKind)
+		a.Equal("1:3: TypeError: unknown operator: INTEGER + BOOLEAN", err.Error())
+	}
+
+	_, err = in.Eval("let = 1;")
+	_, ok = err.(*ParseError)
+	a.True(ok)
+
+	result, err = in.Eval("exit(3)")
+	if a.NoError(err) {
+		a.Equal(&object.Exit{Status: 3}, result)
+	}
+}
+
+func TestEvalFile(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(dir, "lib.mk"), []byte(`export let answer = 42;`), 0644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "main.mk"), []byte(`let lib = import "lib.mk"; lib["answer"]`), 0644))
+
+	result, err := New().EvalFile(filepath.Join(dir, "main.mk"))
+	if a.NoError(err) {
+		a.Equal("42", result.Inspect())
+	}
+
+	_, err = New().EvalFile(filepath.Join(dir, "missing.mk"))
+	a.Error(err)
+}
+
+func TestSetAndGet(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	in.Set("x", &object.Integer{Value: 20})
+	_, err := in.Eval("let y = x + 1")
+	a.NoError(err)
+
+	y, ok := in.Get("y")
+	if a.True(ok) {
+		a.Equal("21", y.Inspect())
+	}
+	_, ok = in.Get("z")
+	a.False(ok)
+}
+
+func TestRegisterFunc(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	in.RegisterFunc("add", func(args ...object.Object) object.Object {
+		sum := int64(0)
+		for _, arg := range args {
+			sum += arg.(*object.Integer).Value
+		}
+		return &object.Integer{Value: sum}
+	})
+	in.RegisterFunc("len", func(args ...object.Object) object.Object {
+		return &object.String{Value: "overridden"}
+	})
+
+	result, err := in.Eval(`[add(1, 2, 3), map([1, 2], fn(x) { add(x, 10) }), len([])]`)
+	if a.NoError(err) {
+		a.Equal("[6, [11, 12], overridden]", result.Inspect())
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	a := assert.New(t)
+	first, second := New(), New()
+
+	first.RegisterFunc("secret", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 1}
+	})
+	_, err := first.Eval("let x = secret()")
+	a.NoError(err)
+
+	_, err = second.Eval("x")
+	a.EqualError(err, "1:1: NameError: identifier not found: x")
+	_, err = second.Eval("secret()")
+	a.EqualError(err, "1:1: NameError: identifier not found: secret")
+
+	first.Limits().MaxDepth = 3
+	_, err = first.Eval("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5)")
+	a.Error(err)
+	result, err := second.Eval("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5)")
+	if a.NoError(err) {
+		a.Equal("5", result.Inspect())
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	a := assert.New(t)
+	in := New()
+
+	_, err := in.EvalContext(context.Background(), "while (true) {}", evaluator.Budget{MaxSteps: 100})
+	errObj, ok := err.(*object.Error)
+	if a.True(ok) {
+		a.Equal(object.STEP_LIMIT_ERROR, errObj.ErrorKind)
+	}
+}
@@ -1,25 +1,51 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey/token"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
+	filename     string
 	input        []rune
 	position     int
-	line         int
 	readPosition int
 	ch           rune
+
+	// Byte offset of `ch`
+	offset int
+	// Line number of the next character
+	line int
+	// Index of the first character of the line where `ch` is
+	lineStart int
+	// Index of the first character of the next line (if `ch` is a newline)
+	nextLineStart int
+
+	// The number of unclosed `{` in each enclosing `${...}` of string literals
+	interpolations []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: []rune(input), line: 1}
+	return NewFile("", input)
+}
+
+// Create a lexer whose tokens record `filename` as their position.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: []rune(input), line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.readPosition > 0 && l.position < len(l.input) {
+		l.offset += utf8.RuneLen(l.input[l.position])
+	}
+	l.lineStart = l.nextLineStart
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -29,6 +55,22 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 	if l.ch == '\n' {
 		l.line += 1
+		l.nextLineStart = l.readPosition
+	}
+}
+
+// Return the position of the current character.
+func (l *Lexer) currentPosition() token.Position {
+	line := l.line
+	if l.ch == '\n' {
+		// `line` already points to the next line
+		line -= 1
+	}
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.offset,
+		Line:     line,
+		Column:   l.position - l.lineStart + 1,
 	}
 }
 
@@ -40,10 +82,20 @@ func (l *Lexer) peekChar() rune {
 	}
 }
 
+// Return the n-th character after the current one without advancing.
+func (l *Lexer) peekCharAt(n int) rune {
+	index := l.position + n
+	if index >= len(l.input) {
+		return 0
+	}
+	return l.input[index]
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	pos := l.currentPosition()
 
 	switch l.ch {
 	case '=':
@@ -51,87 +103,276 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			literal := string(ch) + string(l.ch)
-			tok = newToken(token.Eq, literal, l.line)
+			tok = newToken(token.Eq, literal, pos)
 		} else {
-			tok = newToken(token.Assign, string(l.ch), l.line)
+			tok = newToken(token.Assign, string(l.ch), pos)
 		}
 	case ':':
-		tok = newToken(token.Colon, string(l.ch), l.line)
+		tok = newToken(token.Colon, string(l.ch), pos)
 	case ';':
-		tok = newToken(token.Semicolon, string(l.ch), l.line)
+		tok = newToken(token.Semicolon, string(l.ch), pos)
 	case '(':
-		tok = newToken(token.LParen, string(l.ch), l.line)
+		tok = newToken(token.LParen, string(l.ch), pos)
 	case ')':
-		tok = newToken(token.RParen, string(l.ch), l.line)
+		tok = newToken(token.RParen, string(l.ch), pos)
 	case ',':
-		tok = newToken(token.Comma, string(l.ch), l.line)
+		tok = newToken(token.Comma, string(l.ch), pos)
 	case '+':
-		tok = newToken(token.Plus, string(l.ch), l.line)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(token.PlusAssign, literal, pos)
+		} else {
+			tok = newToken(token.Plus, string(l.ch), pos)
+		}
 	case '-':
-		tok = newToken(token.Minus, string(l.ch), l.line)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(token.MinusAssign, literal, pos)
+		} else {
+			tok = newToken(token.Minus, string(l.ch), pos)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			literal := string(ch) + string(l.ch)
-			tok = newToken(token.Ne, literal, l.line)
+			tok = newToken(token.Ne, literal, pos)
 		} else {
-			tok = newToken(token.Bang, string(l.ch), l.line)
+			tok = newToken(token.Bang, string(l.ch), pos)
 		}
 	case '*':
-		tok = newToken(token.Asterisk, string(l.ch), l.line)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(token.AsteriskAssign, literal, pos)
+		} else {
+			tok = newToken(token.Asterisk, string(l.ch), pos)
+		}
 	case '/':
-		tok = newToken(token.Slash, string(l.ch), l.line)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(token.SlashAssign, literal, pos)
+		} else {
+			tok = newToken(token.Slash, string(l.ch), pos)
+		}
+	case '%':
+		tok = newToken(token.Percent, string(l.ch), pos)
 	case '<':
-		tok = newToken(token.Lt, string(l.ch), l.line)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.Le, pos)
+		case '<':
+			tok = l.readTwoCharToken(token.Shl, pos)
+		default:
+			tok = newToken(token.Lt, string(l.ch), pos)
+		}
 	case '>':
-		tok = newToken(token.Gt, string(l.ch), l.line)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.Ge, pos)
+		case '>':
+			tok = l.readTwoCharToken(token.Shr, pos)
+		default:
+			tok = newToken(token.Gt, string(l.ch), pos)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.And, pos)
+		} else {
+			tok = newToken(token.BitAnd, string(l.ch), pos)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.Or, pos)
+		} else {
+			tok = newToken(token.BitOr, string(l.ch), pos)
+		}
+	case '^':
+		tok = newToken(token.BitXor, string(l.ch), pos)
+	case '.':
+		if l.peekCharAt(1) == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = newToken(token.Ellipsis, "...", pos)
+		} else {
+			tok = newToken(token.Illegal, fmt.Sprintf("unexpected character %q", l.ch), pos)
+		}
 	case '{':
-		tok = newToken(token.LBrace, string(l.ch), l.line)
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1] += 1
+		}
+		tok = newToken(token.LBrace, string(l.ch), pos)
 	case '}':
-		tok = newToken(token.RBrace, string(l.ch), l.line)
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1] == 0 {
+			// The end of `${...}`. Resume the string literal.
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readString(pos, true)
+			break
+		}
+		if n > 0 {
+			l.interpolations[n-1] -= 1
+		}
+		tok = newToken(token.RBrace, string(l.ch), pos)
 	case '[':
-		tok = newToken(token.LBracket, string(l.ch), l.line)
+		tok = newToken(token.LBracket, string(l.ch), pos)
 	case ']':
-		tok = newToken(token.RBracket, string(l.ch), l.line)
+		tok = newToken(token.RBracket, string(l.ch), pos)
 	case '"':
-		tok.Line = l.line
-		tok.Kind = token.String
-		tok.Literal = l.readString()
+		tok = l.readString(pos, false)
+	case '`':
+		tok = l.readRawString(pos)
 	case 0:
 		// Assign empty string instead of null string ("\0")
-		tok = newToken(token.Eof, "", l.line)
+		tok = newToken(token.Eof, "", pos)
 	default:
-		tok.Line = l.line
+		tok.Position = pos
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Kind = token.LookUpIdent(tok.Literal)
 			return tok
 		} else if unicode.IsDigit(l.ch) {
-			tok.Kind = token.Int
-			tok.Literal = l.readNumber()
+			tok.Kind, tok.Literal = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(token.Illegal, string(l.ch), l.line)
+			tok = newToken(token.Illegal, fmt.Sprintf("unexpected character %q", l.ch), pos)
 		}
 	}
 	l.readChar()
 	return tok
 }
 
-func newToken(tokenKind token.TokenKind, literal string, line int) token.Token {
-	return token.Token{Kind: tokenKind, Literal: literal, Line: line}
+func newToken(tokenKind token.TokenKind, literal string, pos token.Position) token.Token {
+	return token.Token{Kind: tokenKind, Literal: literal, Position: pos}
+}
+
+// Read a token which consists of the current and the next characters (e.g. `<=`).
+func (l *Lexer) readTwoCharToken(tokenKind token.TokenKind, pos token.Position) token.Token {
+	ch := l.ch
+	l.readChar()
+	return newToken(tokenKind, string(ch)+string(l.ch), pos)
+}
+
+// Read a string literal with escape sequences (e.g. `"a\tb\n"`).
+// An `Illegal` token is returned if the literal is malformed.
+//
+// A literal with interpolations (e.g. `"a${x}b${y}c"`) is split into a `StringHead` ("a"),
+// a `StringMiddle` ("b") and a `StringTail` ("c") with the tokens of the embedded expressions
+// between them. `resumed` is true if the reading starts at the `}` closing an interpolation.
+func (l *Lexer) readString(pos token.Position, resumed bool) token.Token {
+	var out strings.Builder
+	var illegal *token.Token
+
+	for {
+		l.readChar()
+		switch l.ch {
+		case 0:
+			return newToken(token.Illegal, "unterminated string", pos)
+		case '"':
+			if illegal != nil {
+				return *illegal
+			}
+			if resumed {
+				return newToken(token.StringTail, out.String(), pos)
+			}
+			return newToken(token.String, out.String(), pos)
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			if illegal != nil {
+				return *illegal
+			}
+			if resumed {
+				return newToken(token.StringMiddle, out.String(), pos)
+			}
+			return newToken(token.StringHead, out.String(), pos)
+		case '\\':
+			escapePos := l.currentPosition()
+			l.readChar()
+			if l.ch == 0 {
+				return newToken(token.Illegal, "unterminated string", pos)
+			}
+			if msg := l.readEscape(&out); msg != "" && illegal == nil {
+				// Keep reading until the end of the literal to report only one error
+				tok := newToken(token.Illegal, msg, escapePos)
+				illegal = &tok
+			}
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'$':  '$',
+	'\\': '\\',
+}
+
+// Write the character escaped by the backslash before `ch` into `out`.
+// Return an error message if the escape sequence is invalid.
+func (l *Lexer) readEscape(out *strings.Builder) string {
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteRune(ch)
+		return ""
+	}
+	if l.ch != 'u' {
+		return fmt.Sprintf("invalid escape sequence \\%c", l.ch)
+	}
+
+	// \u{XXXX}
+	if l.peekChar() != '{' {
+		return "invalid unicode escape: expected { after \\u"
+	}
+	l.readChar()
+	start := l.position + 1
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := string(l.input[start : l.position+1])
+	if l.peekChar() != '}' {
+		return "invalid unicode escape: expected hex digits and }"
+	}
+	l.readChar()
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+		return fmt.Sprintf("invalid unicode escape: \\u{%s}", digits)
+	}
+	out.WriteRune(rune(value))
+	return ""
+}
+
+func isHexDigit(ch rune) bool {
+	return ('0' <= ch && ch <= '9') || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
 }
 
-func (l *Lexer) readString() string {
+// Read a raw string literal enclosed in backticks. It may span multiple lines and has no escape sequences.
+func (l *Lexer) readRawString(pos token.Position) token.Token {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case 0:
+			return newToken(token.Illegal, "unterminated raw string", pos)
+		case '`':
+			return newToken(token.String, string(l.input[position:l.position]), pos)
 		}
 	}
-	return string(l.input[position:l.position])
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -146,12 +387,39 @@ func isLetter(ch rune) bool {
 	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func (l *Lexer) readNumber() string {
+// Read an integer (e.g. `42`) or a float (e.g. `3.14`, `1e-9`, `2.5E3`).
+func (l *Lexer) readNumber() (token.TokenKind, string) {
 	position := l.position
+	kind := token.Int
+	l.readDigits()
+
+	// The fraction part needs at least one digit after the dot
+	if l.ch == '.' && unicode.IsDigit(l.peekChar()) {
+		kind = token.Float
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && unicode.IsDigit(l.peekCharAt(2)) {
+			l.readChar()
+			next = l.peekChar()
+		}
+		if unicode.IsDigit(next) {
+			kind = token.Float
+			l.readChar()
+			l.readDigits()
+		}
+	}
+
+	return kind, string(l.input[position:l.position])
+}
+
+func (l *Lexer) readDigits() {
 	for unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
-	return string(l.input[position:l.position])
 }
 
 func (l *Lexer) skipWhitespace() {
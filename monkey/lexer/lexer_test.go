@@ -161,3 +161,234 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 5;\n\tfoo(\"日本\", x)"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{"let", token.Position{Filename: "a.mk", Offset: 0, Line: 1, Column: 1}},
+		{"x", token.Position{Filename: "a.mk", Offset: 4, Line: 1, Column: 5}},
+		{"=", token.Position{Filename: "a.mk", Offset: 6, Line: 1, Column: 7}},
+		{"5", token.Position{Filename: "a.mk", Offset: 8, Line: 1, Column: 9}},
+		{";", token.Position{Filename: "a.mk", Offset: 9, Line: 1, Column: 10}},
+		{"foo", token.Position{Filename: "a.mk", Offset: 12, Line: 2, Column: 2}},
+		{"(", token.Position{Filename: "a.mk", Offset: 15, Line: 2, Column: 5}},
+		{"日本", token.Position{Filename: "a.mk", Offset: 16, Line: 2, Column: 6}},
+		{",", token.Position{Filename: "a.mk", Offset: 24, Line: 2, Column: 10}},
+		{"x", token.Position{Filename: "a.mk", Offset: 26, Line: 2, Column: 12}},
+		{")", token.Position{Filename: "a.mk", Offset: 27, Line: 2, Column: 13}},
+		{"", token.Position{Filename: "a.mk", Offset: 28, Line: 2, Column: 14}},
+	}
+
+	l := NewFile("a.mk", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Position != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%v, got=%v", i, tt.expectedPos, tok.Position)
+		}
+	}
+}
+
+func TestCompoundAssignOperators(t *testing.T) {
+	input := "const x = 1; x += 2; x -= 3; x *= 4; x /= 5;"
+
+	expectedKinds := []token.TokenKind{
+		token.Const, token.Ident, token.Assign, token.Int, token.Semicolon,
+		token.Ident, token.PlusAssign, token.Int, token.Semicolon,
+		token.Ident, token.MinusAssign, token.Int, token.Semicolon,
+		token.Ident, token.AsteriskAssign, token.Int, token.Semicolon,
+		token.Ident, token.SlashAssign, token.Int, token.Semicolon,
+		token.Eof,
+	}
+
+	l := New(input)
+	for i, expected := range expectedKinds {
+		tok := l.NextToken()
+		if tok.Kind != expected {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, expected, tok.Kind)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := "a % b <= c >= d && e || f & g | h ^ i << j >> k < l > m"
+
+	expectedKinds := []token.TokenKind{
+		token.Ident, token.Percent, token.Ident, token.Le, token.Ident, token.Ge, token.Ident,
+		token.And, token.Ident, token.Or, token.Ident, token.BitAnd, token.Ident,
+		token.BitOr, token.Ident, token.BitXor, token.Ident, token.Shl, token.Ident,
+		token.Shr, token.Ident, token.Lt, token.Ident, token.Gt, token.Ident,
+		token.Eof,
+	}
+
+	l := New(input)
+	for i, expected := range expectedKinds {
+		tok := l.NextToken()
+		if tok.Kind != expected {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, expected, tok.Kind)
+		}
+		if tok.Literal != expected.String() && tok.Kind != token.Ident && tok.Kind != token.Eof {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, expected.String(), tok.Literal)
+		}
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := "fn(a, ...rest) { f(...[1.5]) } .."
+
+	expectedKinds := []token.TokenKind{
+		token.Function, token.LParen, token.Ident, token.Comma, token.Ellipsis, token.Ident, token.RParen,
+		token.LBrace, token.Ident, token.LParen, token.Ellipsis, token.LBracket, token.Float, token.RBracket,
+		token.RParen, token.RBrace, token.Illegal,
+	}
+
+	l := New(input)
+	for i, expected := range expectedKinds {
+		tok := l.NextToken()
+		if tok.Kind != expected {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, expected, tok.Kind)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    token.TokenKind
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{`"a\tb\nc"`, token.String, "a\tb\nc", 1, 1},
+		{`"say \"hi\"\r\\"`, token.String, "say \"hi\"\r\\", 1, 1},
+		{`"\u{48}\u{e9}\u{65E5}\u{1F600}"`, token.String, "Hé日😀", 1, 1},
+		{`""`, token.String, "", 1, 1},
+		{"`raw \\n ${x}\nline 2`", token.String, "raw \\n ${x}\nline 2", 1, 1},
+		{`"abc`, token.Illegal, "unterminated string", 1, 1},
+		{`"abc\`, token.Illegal, "unterminated string", 1, 1},
+		{"x `abc\n", token.Illegal, "unterminated raw string", 1, 3},
+		{`"ab\qc"`, token.Illegal, "invalid escape sequence \\q", 1, 4},
+		{"\n  \"\\u{110000}\"", token.Illegal, "invalid unicode escape: \\u{110000}", 2, 4},
+		{`"\u{}"`, token.Illegal, "invalid unicode escape: \\u{}", 1, 2},
+		{`"\u{zz}"`, token.Illegal, "invalid unicode escape: expected hex digits and }", 1, 2},
+		{`"\u0041"`, token.Illegal, "invalid unicode escape: expected { after \\u", 1, 2},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Kind == token.Ident {
+			tok = l.NextToken()
+		}
+
+		if tok.Kind != tt.expectedKind {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, tt.expectedKind, tok.Kind)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"a${x}b${ {"k": "${y}"}["k"] }c" "\${z}$"`
+
+	tests := []struct {
+		expectedKind    token.TokenKind
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.StringHead, "a", 1},
+		{token.Ident, "x", 5},
+		{token.StringMiddle, "b", 6},
+		{token.LBrace, "{", 11},
+		{token.String, "k", 12},
+		{token.Colon, ":", 15},
+		{token.StringHead, "", 17},
+		{token.Ident, "y", 20},
+		{token.StringTail, "", 21},
+		{token.RBrace, "}", 23},
+		{token.LBracket, "[", 24},
+		{token.String, "k", 25},
+		{token.RBracket, "]", 28},
+		{token.StringTail, "c", 30},
+		{token.String, "${z}$", 34},
+		{token.Eof, "", 42},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Kind != tt.expectedKind {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, tt.expectedKind, tok.Kind)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Column)
+		}
+	}
+}
+
+func TestStringFollowedByTokens(t *testing.T) {
+	input := "\"a\\\"b\" `c\nd` \"x\\q\" 1"
+
+	expectedKinds := []token.TokenKind{token.String, token.String, token.Illegal, token.Int, token.Eof}
+
+	l := New(input)
+	for i, expected := range expectedKinds {
+		tok := l.NextToken()
+		if tok.Kind != expected {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, expected, tok.Kind)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "42 3.14 1e9 2.5E-3 7e+2 1.x 5ex 1e-"
+
+	tests := []struct {
+		expectedKind    token.TokenKind
+		expectedLiteral string
+	}{
+		{token.Int, "42"},
+		{token.Float, "3.14"},
+		{token.Float, "1e9"},
+		{token.Float, "2.5E-3"},
+		{token.Float, "7e+2"},
+		{token.Int, "1"},
+		{token.Illegal, "unexpected character '.'"},
+		{token.Ident, "x"},
+		{token.Int, "5"},
+		{token.Ident, "ex"},
+		{token.Int, "1"},
+		{token.Ident, "e"},
+		{token.Minus, "-"},
+		{token.Eof, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Kind != tt.expectedKind {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%q, got=%q", i, tt.expectedKind, tok.Kind)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -1,13 +1,33 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
 func main() {
+	engine := flag.String("engine", repl.EngineEval, "execution engine: 'eval' or 'vm'")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [script [args...]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Fprintf(os.Stderr, "unknown engine: %s\n", *engine)
+		os.Exit(2)
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runFile(flag.Arg(0), flag.Args()[1:], *engine))
+	}
+
 	user, err := user.Current()
 
 	if err != nil {
@@ -15,5 +35,57 @@ func main() {
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in command\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, *engine)
+}
+
+// Run the script at `path` and return the exit status of the process.
+// The script receives `args` through the global variable `args`.
+func runFile(path string, args []string, engine string) int {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	l := lexer.NewFile(path, string(input))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.DetailedErrors() {
+			fmt.Fprintln(os.Stderr, err.Error())
+			fmt.Fprintln(os.Stderr, err.Pos.Annotate(string(input)))
+		}
+		return 1
+	}
+
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	bindings := map[string]object.Object{
+		"args": &object.Array{Elements: elements},
+	}
+
+	evaluated, err := repl.NewRunner(engine, bindings)(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 1
+	}
+
+	switch evaluated := evaluated.(type) {
+	case *object.Exit:
+		return evaluated.Status
+	case *object.Error:
+		if evaluated.Pos.IsValid() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", evaluated.Pos, evaluated.Inspect())
+			fmt.Fprintln(os.Stderr, evaluated.Pos.Annotate(string(input)))
+			if len(evaluated.Stack) > 0 {
+				fmt.Fprint(os.Stderr, "\n"+evaluated.StackTrace())
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, evaluated.Inspect())
+		}
+		return 1
+	}
+	return 0
 }
@@ -1,21 +1,87 @@
 package object
 
+import "context"
+
+// The default maximum depth of nested function calls.
+// It is far below the depth at which the Go stack overflows.
+const DefaultMaxDepth = 10000
+
+// Limits of an evaluation. They are a part of `Runtime`, so each interpreter can configure its own limits.
+type Limits struct {
+	// The maximum depth of nested function calls. It is unlimited if zero or negative.
+	MaxDepth int
+	// The current depth of nested function calls
+	Depth int
+	// The evaluation is cancelled when it is done. It is never cancelled if nil.
+	Context context.Context
+	// The maximum number of evaluation steps (i.e. evaluated nodes). It is unlimited if zero or negative.
+	MaxSteps int
+	// The number of evaluation steps so far
+	Steps int
+	// The approximate maximum number of bytes allocated for strings, arrays and hashes.
+	// It is unlimited if zero or negative.
+	MaxAllocs int
+	// The approximate number of bytes allocated so far
+	Allocs int
+}
+
+// The state of an interpreter. It is shared by all of its scopes, including
+// the global scopes of imported modules, and never by other interpreters.
+type Runtime struct {
+	Limits Limits
+	// Builtins registered by the host. They take precedence over the standard builtins.
+	Builtins map[string]*Builtin
+	// Modules which have been imported, keyed by their absolute paths
+	Modules map[string]*Hash
+	// Absolute paths of modules being evaluated, from the outermost one.
+	// It is used to detect import cycles.
+	ImportStack []string
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		Limits:   Limits{MaxDepth: DefaultMaxDepth},
+		Builtins: make(map[string]*Builtin),
+		Modules:  make(map[string]*Hash),
+	}
+}
+
 type Environment struct {
 	store map[string]Object
-	outer *Environment
+	// Names defined by `const`
+	consts  map[string]bool
+	outer   *Environment
+	runtime *Runtime
 }
 
+// Create the global scope of a new interpreter.
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithRuntime(NewRuntime())
+}
+
+// Create a global scope of the interpreter `runtime` (e.g. for an imported module).
+func NewEnvironmentWithRuntime(runtime *Runtime) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	c := make(map[string]bool)
+	return &Environment{store: s, consts: c, outer: nil, runtime: runtime}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := NewEnvironmentWithRuntime(outer.runtime)
 	env.outer = outer
 	return env
 }
 
+// Return the state of the interpreter which this scope belongs to.
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+// Return the limits of the evaluation in this scope.
+func (e *Environment) Limits() *Limits {
+	return &e.runtime.Limits
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -26,5 +92,29 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
+	delete(e.consts, name)
+	return val
+}
+
+// Define a constant `name` in this scope.
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.store[name] = val
+	e.consts[name] = true
 	return val
 }
+
+// Judge if `name` is a constant defined in this scope.
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// Return the innermost scope where `name` is defined.
+func (e *Environment) Scope(name string) (*Environment, bool) {
+	if _, ok := e.store[name]; ok {
+		return e, true
+	}
+	if e.outer != nil {
+		return e.outer.Scope(name)
+	}
+	return nil, false
+}
@@ -0,0 +1,135 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/token"
+)
+
+// A category of runtime errors
+type ErrorKind int
+
+const (
+	RUNTIME_ERROR ErrorKind = iota
+	TYPE_ERROR
+	NAME_ERROR
+	ARGUMENT_ERROR
+	VALUE_ERROR
+	INDEX_ERROR
+	ZERO_DIVISION_ERROR
+	IMPORT_ERROR
+	// Raised by `throw`
+	USER_ERROR
+	// Raised when a sandboxed evaluation is aborted. They cannot be caught by `try`.
+	CANCELLED_ERROR
+	STEP_LIMIT_ERROR
+	MEMORY_LIMIT_ERROR
+)
+
+func (ek ErrorKind) String() string {
+	switch ek {
+	case RUNTIME_ERROR:
+		return "RuntimeError"
+	case TYPE_ERROR:
+		return "TypeError"
+	case NAME_ERROR:
+		return "NameError"
+	case ARGUMENT_ERROR:
+		return "ArgumentError"
+	case VALUE_ERROR:
+		return "ValueError"
+	case INDEX_ERROR:
+		return "IndexError"
+	case ZERO_DIVISION_ERROR:
+		return "ZeroDivisionError"
+	case IMPORT_ERROR:
+		return "ImportError"
+	case USER_ERROR:
+		return "UserError"
+	case CANCELLED_ERROR:
+		return "CancelledError"
+	case STEP_LIMIT_ERROR:
+		return "StepLimitError"
+	case MEMORY_LIMIT_ERROR:
+		return "MemoryLimitError"
+	default:
+		return "<error kind>"
+	}
+}
+
+// A function call which an error propagated through
+type StackFrame struct {
+	// The name of the called function. It is empty for anonymous functions.
+	Function string
+	// Where the function was called
+	CallSite token.Position
+}
+
+type Error struct {
+	ErrorKind ErrorKind
+	Message   string
+	// Where the error occurred. It is invalid if unknown.
+	Pos token.Position
+	// Function calls from the innermost one
+	Stack []StackFrame
+	// The value passed to `throw`. It is nil for errors raised by the interpreter.
+	Value Object
+}
+
+func (e *Error) Kind() ObjectKind { return ERROR }
+func (e *Error) Inspect() string  { return "Error: " + e.Message }
+
+// Implement `error` for hosts. The position is prepended if it is known.
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s: %s", e.Pos, e.ErrorKind, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.ErrorKind, e.Message)
+}
+
+// Return the call stack in the style of Go panics, e.g.
+//
+//	TypeError: unknown operator: INTEGER + BOOLEAN
+//
+//	inner(...)
+//		a.mk:2:5
+//	outer(...)
+//		a.mk:5:3
+//	main
+//		a.mk:7:1
+//
+// Each function is followed by the position which was executed in it.
+// Consecutive identical frames (e.g. of deep recursion) are collapsed into one.
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	fmt.Fprintf(&out, "%s: %s\n\n", e.ErrorKind, e.Message)
+
+	pos := e.Pos
+	previous, repeated := "", 0
+	for _, frame := range e.Stack {
+		name := frame.Function
+		if name == "" {
+			name = "<anonymous>"
+		}
+		entry := fmt.Sprintf("%s(...)\n\t%s\n", name, pos)
+		pos = frame.CallSite
+		if entry == previous {
+			repeated++
+			continue
+		}
+		writeRepeated(&out, repeated)
+		out.WriteString(entry)
+		previous, repeated = entry, 0
+	}
+	writeRepeated(&out, repeated)
+	fmt.Fprintf(&out, "main\n\t%s\n", pos)
+
+	return out.String()
+}
+
+func writeRepeated(out *bytes.Buffer, repeated int) {
+	if repeated > 0 {
+		fmt.Fprintf(out, "[previous frame repeated %d more times]\n", repeated)
+	}
+}
@@ -0,0 +1,117 @@
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Judge if `obj` can be used as a hash key.
+func IsHashable(obj Object) bool {
+	_, ok := HashKeyOf(obj)
+	return ok
+}
+
+// Return the hash key of `obj`, or false if it is not usable as a hash key.
+// Arrays and hashes are hashed by their contents, so they are usable only if
+// all of their elements (keys and values of hashes) are usable and they do not contain themselves.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	return hashKeyOf(obj, map[Object]bool{})
+}
+
+// `inProgress` holds the arrays and hashes being hashed to detect cycles.
+func hashKeyOf(obj Object, inProgress map[Object]bool) (HashKey, bool) {
+	switch obj.(type) {
+	case *Array, *Hash:
+		if inProgress[obj] {
+			return HashKey{}, false
+		}
+		inProgress[obj] = true
+		defer delete(inProgress, obj)
+	}
+
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		var data strings.Builder
+		for _, el := range obj.Elements {
+			key, ok := hashKeyOf(el, inProgress)
+			if !ok {
+				return HashKey{}, false
+			}
+			data.WriteString(encodeHashKey(key))
+		}
+		return newContentHashKey(ARRAY, data.String()), true
+	case *Hash:
+		// Equal hashes have the same key regardless of the insertion order
+		pairs := make([]string, 0, len(obj.Pairs))
+		for hashKey, pair := range obj.Pairs {
+			valueKey, ok := hashKeyOf(pair.Value, inProgress)
+			if !ok {
+				return HashKey{}, false
+			}
+			pairs = append(pairs, encodeHashKey(hashKey)+encodeHashKey(valueKey))
+		}
+		sort.Strings(pairs)
+		return newContentHashKey(HASH, strings.Join(pairs, "")), true
+	default:
+		return HashKey{}, false
+	}
+}
+
+func newContentHashKey(kind ObjectKind, data string) HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(data))
+	return HashKey{Kind: kind, Value: h.Sum64(), Data: data}
+}
+
+// Encode `key` into a string. Lengths are prefixed so that concatenated
+// encodings are not ambiguous.
+func encodeHashKey(key HashKey) string {
+	return fmt.Sprintf("%d:%d:%d:%s", key.Kind, key.Value, len(key.Data), key.Data)
+}
+
+// Return a frozen copy of arrays and hashes (including nested ones).
+// Other objects are returned as is.
+func freeze(obj Object) Object {
+	return freezeCopy(obj, map[Object]Object{})
+}
+
+// `copies` maps arrays and hashes to their frozen copies, so that shared or
+// cyclic values are copied only once.
+func freezeCopy(obj Object, copies map[Object]Object) Object {
+	if frozen, ok := copies[obj]; ok {
+		return frozen
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return obj
+		}
+		frozen := &Array{Elements: make([]Object, len(obj.Elements)), Frozen: true}
+		copies[obj] = frozen
+		for i, el := range obj.Elements {
+			frozen.Elements[i] = freezeCopy(el, copies)
+		}
+		return frozen
+	case *Hash:
+		if obj.Frozen {
+			return obj
+		}
+		frozen := NewHash()
+		copies[obj] = frozen
+		for _, pair := range obj.OrderedPairs() {
+			// Keys are already frozen
+			hashKey, _ := HashKeyOf(pair.Key)
+			frozen.keys = append(frozen.keys, hashKey)
+			frozen.Pairs[hashKey] = HashPair{Key: pair.Key, Value: freezeCopy(pair.Value, copies)}
+		}
+		frozen.Frozen = true
+		return frozen
+	default:
+		return obj
+	}
+}
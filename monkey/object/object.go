@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
+	"monkey/code"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +16,8 @@ type ObjectKind int
 
 const (
 	INTEGER ObjectKind = iota
+	BIG_INTEGER
+	FLOAT
 	BOOLEAN
 	RETURN_VALUE
 	ERROR
@@ -22,12 +28,23 @@ const (
 	STRING
 	EXIT
 	NULL
+	COMPILED_FUNCTION
+	CLOSURE
+	CELL
+	ITERATOR
+	BREAK
+	CONTINUE
+	TAIL_CALL
 )
 
 func (ok ObjectKind) String() string {
 	switch ok {
 	case INTEGER:
 		return "INTEGER"
+	case BIG_INTEGER:
+		return "BIG_INTEGER"
+	case FLOAT:
+		return "FLOAT"
 	case BOOLEAN:
 		return "BOOLEAN"
 	case RETURN_VALUE:
@@ -48,6 +65,20 @@ func (ok ObjectKind) String() string {
 		return "EXIT"
 	case NULL:
 		return "NULL"
+	case COMPILED_FUNCTION:
+		return "COMPILED_FUNCTION"
+	case CLOSURE:
+		return "CLOSURE"
+	case CELL:
+		return "CELL"
+	case ITERATOR:
+		return "ITERATOR"
+	case BREAK:
+		return "BREAK"
+	case CONTINUE:
+		return "CONTINUE"
+	case TAIL_CALL:
+		return "TAIL_CALL"
 	default:
 		return "<error kind>"
 	}
@@ -61,6 +92,9 @@ type Object interface {
 type HashKey struct {
 	Kind  ObjectKind
 	Value uint64
+	// The content of the key for kinds whose `Value` is a hash (e.g. strings).
+	// Keys of different objects never equal even if their hashes collide.
+	Data string
 }
 
 type Hashable interface {
@@ -77,6 +111,50 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Kind: i.Kind(), Value: uint64(i.Value)}
 }
 
+// An integer which does not fit in int64. Arithmetic results are demoted to
+// `Integer` whenever they fit again, so a `BigInteger` is never in int64 range.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Kind() ObjectKind { return BIG_INTEGER }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) HashKey() HashKey {
+	// Not created by arithmetic, but the host may create one in int64 range
+	if bi.Value.IsInt64() {
+		return (&Integer{Value: bi.Value.Int64()}).HashKey()
+	}
+	h := fnv.New64a()
+	h.Write([]byte{byte(bi.Value.Sign() + 1)})
+	h.Write(bi.Value.Bytes())
+	return HashKey{Kind: bi.Kind(), Value: h.Sum64(), Data: bi.Value.String()}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Kind() ObjectKind { return FLOAT }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// Distinguish integral values from integers (e.g. "2.0" instead of "2")
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) HashKey() HashKey {
+	// Integral values are the same key as the equal integers (e.g. `2.0` and `2`)
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+			return (&Integer{Value: int64(f.Value)}).HashKey()
+		}
+		value, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInteger{Value: value}).HashKey()
+	}
+	return HashKey{Kind: f.Kind(), Value: math.Float64bits(f.Value)}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -100,31 +178,47 @@ type ReturnValue struct {
 func (rv *ReturnValue) Kind() ObjectKind { return RETURN_VALUE }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
-type Error struct {
-	Message string
+// A signal of `break` which unwinds statements until the innermost loop
+type Break struct{}
+
+func (b *Break) Kind() ObjectKind { return BREAK }
+func (b *Break) Inspect() string  { return "break" }
+
+// A signal of `continue` which unwinds statements until the innermost loop
+type Continue struct{}
+
+func (c *Continue) Kind() ObjectKind { return CONTINUE }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// A call in tail position which is performed by the caller after the current
+// function returns, so that tail calls do not grow the Go stack.
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+	Call      *ast.CallExpression
 }
 
-func (e *Error) Kind() ObjectKind { return ERROR }
-func (e *Error) Inspect() string  { return "Error: " + e.Message }
+func (tc *TailCall) Kind() ObjectKind { return TAIL_CALL }
+func (tc *TailCall) Inspect() string  { return "tail call" }
 
 type Function struct {
+	// The name bound by `let`. It is empty for anonymous functions.
+	Name       string
 	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// Evaluated at each call if the argument is omitted. See `ast.FunctionLiteral`.
+	Defaults []ast.Expression
+	Rest     *ast.Identifier
+	Body     *ast.BlockStatement
+	Env      *Environment
 }
 
 func (f *Function) Kind() ObjectKind { return FUNCTION }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParametersString(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(")")
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
@@ -134,23 +228,64 @@ func (f *Function) Inspect() string {
 }
 
 type BuiltinFunction func(args ...Object) Object
+
+// Call a function object (e.g. `Function`, `Closure` or `Builtin`) with `args`.
+// Each execution engine provides its own caller.
+type Caller func(fn Object, args ...Object) Object
+
+// A builtin which calls back functions given as arguments (e.g. `map`).
+type HigherOrderFunction func(call Caller, args ...Object) Object
+
+// A builtin whose work is proportional to its arguments (e.g. `range`). It checks `limits`
+// before allocating its result and while looping. `limits` is nil if they are not enforced.
+type LimitedFunction func(limits *Limits, args ...Object) Object
+
 type Builtin struct {
 	Fn BuiltinFunction
+	// Used instead of `Fn` if set
+	HigherOrderFn HigherOrderFunction
+	// Used instead of `Fn` if set
+	LimitedFn LimitedFunction
 }
 
 func (b *Builtin) Kind() ObjectKind { return BUILTIN }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+// Call the builtin. `call` is used to call back functions given as arguments.
+// `limits` are the limits of the evaluation, or nil if they are not enforced.
+func (b *Builtin) Call(call Caller, limits *Limits, args ...Object) Object {
+	switch {
+	case b.HigherOrderFn != nil:
+		return b.HigherOrderFn(call, args...)
+	case b.LimitedFn != nil:
+		return b.LimitedFn(limits, args...)
+	default:
+		return b.Fn(args...)
+	}
+}
+
 type Array struct {
 	Elements []Object
+	// A frozen array cannot be modified. Arrays used as hash keys are frozen.
+	Frozen bool
 }
 
 func (a *Array) Kind() ObjectKind { return ARRAY }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return a.inspect(map[Object]bool{}) }
+
+// `inProgress` holds the arrays and hashes being inspected. They are printed
+// as `[...]` or `{...}` when they are reached again, so cycles do not recurse forever.
+func (a *Array) inspect(inProgress map[Object]bool) string {
+	if inProgress[a] {
+		return "[...]"
+	}
+	inProgress[a] = true
+	defer delete(inProgress, a)
+
 	var out bytes.Buffer
 	elements := []string{}
 	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspect(e, inProgress))
 	}
 
 	out.WriteString("[")
@@ -169,17 +304,92 @@ func (hp *HashPair) Inspect() string {
 	return fmt.Sprintf("%s: %s", hp.Key.Inspect(), hp.Value.Inspect())
 }
 
+// A hash which remembers the insertion order of keys.
+// Use `Set` and `Delete` to update it so that the order is kept.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	// Keys in the insertion order
+	keys []HashKey
+	// A frozen hash cannot be modified. Hashes used as hash keys are frozen.
+	Frozen bool
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Associate `value` with `key`. It returns false without modifying the hash
+// if `key` does not satisfy `IsHashable`. Updating an existing key keeps its position
+// and the original key object (e.g. `2` when `2.0` is set).
+// Arrays and hashes are copied and frozen so that the key does not change.
+func (h *Hash) Set(key, value Object) bool {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return false
+	}
+	if pair, ok := h.Pairs[hashKey]; ok {
+		h.Pairs[hashKey] = HashPair{Key: pair.Key, Value: value}
+		return true
+	}
+	h.keys = append(h.keys, hashKey)
+	h.Pairs[hashKey] = HashPair{Key: freeze(key), Value: value}
+	return true
+}
+
+// Return the value associated with `key`.
+func (h *Hash) Get(key Object) (Object, bool) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return nil, false
+	}
+	pair, ok := h.Pairs[hashKey]
+	return pair.Value, ok
+}
+
+// Remove `key` and return if it existed.
+func (h *Hash) Delete(key Object) bool {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return false
+	}
+	if _, ok := h.Pairs[hashKey]; !ok {
+		return false
+	}
+	delete(h.Pairs, hashKey)
+	for i, k := range h.keys {
+		if k == hashKey {
+			h.keys = append(h.keys[:i:i], h.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Return the pairs in the insertion order.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, len(h.keys))
+	for i, k := range h.keys {
+		pairs[i] = h.Pairs[k]
+	}
+	return pairs
 }
 
 func (h *Hash) Kind() ObjectKind { return HASH }
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string  { return h.inspect(map[Object]bool{}) }
+
+// See `Array.inspect`.
+func (h *Hash) inspect(inProgress map[Object]bool) string {
+	if inProgress[h] {
+		return "{...}"
+	}
+	inProgress[h] = true
+	defer delete(inProgress, h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, pair.Inspect())
+	for _, pair := range h.OrderedPairs() {
+		pairs = append(pairs, inspect(pair.Key, inProgress)+": "+inspect(pair.Value, inProgress))
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -188,6 +398,18 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// Inspect `obj` which is an element of an array or a hash. See `Array.inspect`.
+func inspect(obj Object, inProgress map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.inspect(inProgress)
+	case *Hash:
+		return obj.inspect(inProgress)
+	default:
+		return obj.Inspect()
+	}
+}
+
 type String struct {
 	Value string
 }
@@ -197,7 +419,7 @@ func (s *String) Inspect() string  { return s.Value }
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
-	return HashKey{Kind: s.Kind(), Value: h.Sum64()}
+	return HashKey{Kind: s.Kind(), Value: h.Sum64(), Data: s.Value}
 }
 
 type Exit struct {
@@ -211,3 +433,60 @@ type Null struct{}
 
 func (n *Null) Kind() ObjectKind { return NULL }
 func (n *Null) Inspect() string  { return "null" }
+
+// A function compiled into bytecode. It is only used by `vm`.
+type CompiledFunction struct {
+	Instructions code.Instructions
+	NumLocals    int
+	// The number of parameters except the rest parameter
+	NumParameters int
+	// The number of trailing parameters with default values
+	NumDefaults int
+	// True if the extra arguments are collected into the rest parameter
+	Variadic bool
+	// The name bound by `let`. It is empty for anonymous functions.
+	Name string
+	// Source positions of the instructions and the functions called by `OpCall`
+	Positions code.SourceMap
+	CallSites code.SourceMap
+}
+
+func (cf *CompiledFunction) Kind() ObjectKind { return COMPILED_FUNCTION }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// A compiled function with its captured free variables. It is only used by `vm`.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+	// The constants and the globals of the program (or the module) which defines the function
+	Constants []Object
+	Globals   []Object
+}
+
+func (c *Closure) Kind() ObjectKind { return CLOSURE }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// A variable captured by closures. The closures and the frame which defines the variable
+// share it so that assignments are visible to all of them. It is only used by `vm`.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Kind() ObjectKind { return CELL }
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%p]", c)
+}
+
+// The state of a `for-in` loop. It is only used by `vm`.
+type Iterator struct {
+	Items []Object
+	// The index of the next item
+	Next int
+}
+
+func (it *Iterator) Kind() ObjectKind { return ITERATOR }
+func (it *Iterator) Inspect() string  { return "iterator" }
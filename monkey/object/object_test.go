@@ -1,6 +1,9 @@
 package object
 
 import (
+	"math"
+	"math/big"
+	"monkey/token"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,3 +21,168 @@ func TestStringHashKey(t *testing.T) {
 	a.Equal(diff1.HashKey(), diff2.HashKey())
 	a.NotEqual(hello1.HashKey(), diff1.HashKey())
 }
+
+func TestBigIntegerHashKey(t *testing.T) {
+	a := assert.New(t)
+
+	big1, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	big2, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	neg := new(big.Int).Neg(big1)
+
+	a.Equal((&BigInteger{Value: big1}).HashKey(), (&BigInteger{Value: big2}).HashKey())
+	a.NotEqual((&BigInteger{Value: big1}).HashKey(), (&BigInteger{Value: neg}).HashKey())
+	a.Equal("-123456789012345678901234567890", (&BigInteger{Value: neg}).Inspect())
+}
+
+func TestNumberHashKey(t *testing.T) {
+	a := assert.New(t)
+
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	a.Equal((&Integer{Value: 2}).HashKey(), (&Float{Value: 2.0}).HashKey())
+	a.Equal((&Integer{Value: 0}).HashKey(), (&Float{Value: math.Copysign(0, -1)}).HashKey())
+	a.Equal((&Integer{Value: math.MinInt64}).HashKey(), (&Float{Value: math.MinInt64}).HashKey())
+	a.Equal((&BigInteger{Value: huge}).HashKey(), (&Float{Value: 1e20}).HashKey())
+	a.Equal((&Integer{Value: 2}).HashKey(), (&BigInteger{Value: big.NewInt(2)}).HashKey())
+	a.NotEqual((&Integer{Value: 2}).HashKey(), (&Float{Value: 2.5}).HashKey())
+	a.NotEqual((&Float{Value: math.Inf(1)}).HashKey(), (&Float{Value: math.Inf(-1)}).HashKey())
+}
+
+func TestCompositeHashKey(t *testing.T) {
+	a := assert.New(t)
+
+	pair := func(x, y int64) *Array {
+		return &Array{Elements: []Object{&Integer{Value: x}, &Integer{Value: y}}}
+	}
+	key1, ok1 := HashKeyOf(pair(1, 2))
+	key2, ok2 := HashKeyOf(pair(1, 2))
+	key3, _ := HashKeyOf(pair(2, 1))
+	a.True(ok1 && ok2)
+	a.Equal(key1, key2)
+	a.NotEqual(key1, key3)
+
+	// Nesting must not be ambiguous
+	nested, _ := HashKeyOf(&Array{Elements: []Object{pair(1, 2)}})
+	a.NotEqual(key1, nested)
+
+	hash1, hash2 := NewHash(), NewHash()
+	hash1.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash1.Set(&String{Value: "b"}, &Integer{Value: 2})
+	hash2.Set(&String{Value: "b"}, &Integer{Value: 2})
+	hash2.Set(&String{Value: "a"}, &Integer{Value: 1})
+	key4, _ := HashKeyOf(hash1)
+	key5, _ := HashKeyOf(hash2)
+	a.Equal(key4, key5)
+
+	a.False(IsHashable(&Array{Elements: []Object{&Function{}}}))
+	a.False(IsHashable(&Null{}))
+}
+
+func TestHashKeyCollision(t *testing.T) {
+	a := assert.New(t)
+
+	// Simulate strings whose hashes collide
+	key1 := (&String{Value: "one"}).HashKey()
+	key2 := (&String{Value: "two"}).HashKey()
+	key2.Value = key1.Value
+
+	pairs := map[HashKey]int{key1: 1, key2: 2}
+	a.Len(pairs, 2)
+}
+
+func TestCyclicHashKey(t *testing.T) {
+	a := assert.New(t)
+
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements[0] = array
+	a.False(IsHashable(array))
+
+	hash := NewHash()
+	hash.Set(&String{Value: "self"}, hash)
+	a.False(IsHashable(hash))
+
+	a.False(hash.Set(array, &Integer{Value: 1}))
+	a.Len(hash.Pairs, 1)
+
+	// Shared values are not cycles
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+	a.True(hash.Set(&Array{Elements: []Object{shared, shared}}, &Integer{Value: 2}))
+	stored := hash.OrderedPairs()[1].Key.(*Array)
+	a.Same(stored.Elements[0], stored.Elements[1])
+}
+
+func TestHashFreezesKeys(t *testing.T) {
+	a := assert.New(t)
+
+	key := &Array{Elements: []Object{&Integer{Value: 1}}}
+	hash := NewHash()
+	hash.Set(key, &Integer{Value: 1})
+	key.Elements[0] = &Integer{Value: 2}
+
+	stored := hash.OrderedPairs()[0].Key.(*Array)
+	a.True(stored.Frozen)
+	a.False(key.Frozen)
+	a.Equal("[1]", stored.Inspect())
+
+	_, ok := hash.Get(&Array{Elements: []Object{&Integer{Value: 1}}})
+	a.True(ok)
+}
+
+func TestInspectCycles(t *testing.T) {
+	a := assert.New(t)
+
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	hash := NewHash()
+	hash.Set(&String{Value: "array"}, array)
+	array.Elements = append(array.Elements, hash, array)
+
+	a.Equal("[1, {array: [...]}, [...]]", array.Inspect())
+	a.Equal("{array: [1, {...}, [...]]}", hash.Inspect())
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	a := assert.New(t)
+
+	err := &Error{
+		ErrorKind: NAME_ERROR,
+		Message:   "identifier not found: y",
+		Pos:       token.Position{Line: 2, Column: 7},
+		Stack: []StackFrame{
+			{Function: "inner", CallSite: token.Position{Line: 5, Column: 11}},
+			{Function: "", CallSite: token.Position{Line: 8, Column: 1}},
+		},
+	}
+
+	expected := `NameError: identifier not found: y
+
+inner(...)
+	2:7
+<anonymous>(...)
+	5:11
+main
+	8:1
+`
+	a.Equal(expected, err.StackTrace())
+}
+
+func TestErrorStackTraceCollapsesRepeatedFrames(t *testing.T) {
+	a := assert.New(t)
+
+	frame := StackFrame{Function: "f", CallSite: token.Position{Line: 1, Column: 5}}
+	err := &Error{
+		ErrorKind: RUNTIME_ERROR,
+		Message:   "maximum recursion depth exceeded at fn f",
+		Pos:       token.Position{Line: 1, Column: 5},
+		Stack:     []StackFrame{frame, frame, frame, {Function: "f", CallSite: token.Position{Line: 2, Column: 1}}},
+	}
+
+	expected := `RuntimeError: maximum recursion depth exceeded at fn f
+
+f(...)
+	1:5
+[previous frame repeated 3 more times]
+main
+	2:1
+`
+	a.Equal(expected, err.StackTrace())
+}
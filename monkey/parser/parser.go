@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
@@ -9,9 +10,14 @@ import (
 )
 
 // Operator coupling predences
+// Bitwise operators are coupled like Go: `|` and `^` are as strong as `+`,
+// `&`, `<<` and `>>` are as strong as `*`.
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
 	SUM
@@ -22,16 +28,31 @@ const (
 )
 
 var precedences = map[token.TokenKind]int{
-	token.Eq:       EQUALS,
-	token.Ne:       EQUALS,
-	token.Lt:       LESSGREATER,
-	token.Gt:       LESSGREATER,
-	token.Plus:     SUM,
-	token.Minus:    SUM,
-	token.Asterisk: PRODUCT,
-	token.Slash:    PRODUCT,
-	token.LParen:   CALL,
-	token.LBracket: LBRACKET,
+	token.Assign:         ASSIGN,
+	token.PlusAssign:     ASSIGN,
+	token.MinusAssign:    ASSIGN,
+	token.AsteriskAssign: ASSIGN,
+	token.SlashAssign:    ASSIGN,
+	token.Or:             LOGICAL_OR,
+	token.And:            LOGICAL_AND,
+	token.Eq:             EQUALS,
+	token.Ne:             EQUALS,
+	token.Lt:             LESSGREATER,
+	token.Gt:             LESSGREATER,
+	token.Le:             LESSGREATER,
+	token.Ge:             LESSGREATER,
+	token.Plus:           SUM,
+	token.Minus:          SUM,
+	token.BitOr:          SUM,
+	token.BitXor:         SUM,
+	token.Asterisk:       PRODUCT,
+	token.Slash:          PRODUCT,
+	token.Percent:        PRODUCT,
+	token.BitAnd:         PRODUCT,
+	token.Shl:            PRODUCT,
+	token.Shr:            PRODUCT,
+	token.LParen:         CALL,
+	token.LBracket:       LBRACKET,
 }
 
 type (
@@ -41,28 +62,47 @@ type (
 	infixParseFn func(ast.Expression) ast.Expression
 )
 
+// An error detected while parsing
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+// Return "<position>: <message>".
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*Error
 
 	curToken  token.Token
 	peekToken token.Token
 
 	prefixParseFns map[token.TokenKind]prefixParseFn
 	infixParseFns  map[token.TokenKind]infixParseFn
+
+	// The number of loops enclosing the current token in the current function
+	loopDepth int
+	// The number of blocks enclosing the current token
+	blockDepth int
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*Error{}}
 
 	// Read first two tokens (curToken and peekToken)
 	p.nextToken()
 	p.nextToken()
 
 	p.prefixParseFns = make(map[token.TokenKind]prefixParseFn)
+	p.registerPrefix(token.Illegal, p.parseIllegal)
 	p.registerPrefix(token.String, p.parseStringLiteral)
+	p.registerPrefix(token.StringHead, p.parseInterpolatedString)
 	p.registerPrefix(token.Ident, p.parseIdentifier)
 	p.registerPrefix(token.Int, p.parseIntegerLiteral)
+	p.registerPrefix(token.Float, p.parseFloatLiteral)
 	p.registerPrefix(token.Bang, p.parsePrefixExpression)
 	p.registerPrefix(token.Minus, p.parsePrefixExpression)
 	p.registerPrefix(token.True, p.parseBoolean)
@@ -72,6 +112,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LParen, p.parseGroupedExpression)
 	p.registerPrefix(token.If, p.parseIfExpression)
 	p.registerPrefix(token.Function, p.parseFunctionLiteral)
+	p.registerPrefix(token.Try, p.parseTryExpression)
+	p.registerPrefix(token.Import, p.parseImportExpression)
 
 	p.infixParseFns = make(map[token.TokenKind]infixParseFn)
 	p.registerInfix(token.Plus, p.parseInfixExpression)
@@ -82,6 +124,21 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.Ne, p.parseInfixExpression)
 	p.registerInfix(token.Lt, p.parseInfixExpression)
 	p.registerInfix(token.Gt, p.parseInfixExpression)
+	p.registerInfix(token.Le, p.parseInfixExpression)
+	p.registerInfix(token.Ge, p.parseInfixExpression)
+	p.registerInfix(token.Percent, p.parseInfixExpression)
+	p.registerInfix(token.And, p.parseInfixExpression)
+	p.registerInfix(token.Or, p.parseInfixExpression)
+	p.registerInfix(token.BitAnd, p.parseInfixExpression)
+	p.registerInfix(token.BitOr, p.parseInfixExpression)
+	p.registerInfix(token.BitXor, p.parseInfixExpression)
+	p.registerInfix(token.Shl, p.parseInfixExpression)
+	p.registerInfix(token.Shr, p.parseInfixExpression)
+	p.registerInfix(token.Assign, p.parseAssignExpression)
+	p.registerInfix(token.PlusAssign, p.parseAssignExpression)
+	p.registerInfix(token.MinusAssign, p.parseAssignExpression)
+	p.registerInfix(token.AsteriskAssign, p.parseAssignExpression)
+	p.registerInfix(token.SlashAssign, p.parseAssignExpression)
 	p.registerInfix(token.LParen, p.parseCallExpression)
 	p.registerInfix(token.LBracket, p.parseIndexExpression)
 
@@ -111,10 +168,20 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Kind {
-	case token.Let:
+	case token.Let, token.Const:
 		return p.parseLetStatement()
 	case token.Return:
 		return p.parseReturnStatement()
+	case token.Throw:
+		return p.parseThrowStatement()
+	case token.While:
+		return p.parseWhileStatement()
+	case token.For:
+		return p.parseForInStatement()
+	case token.Break, token.Continue:
+		return p.parseLoopControlStatement()
+	case token.Export:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -136,6 +203,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	// Skip assign(=) token
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
 
 	// Skip semicolon(;) token if exists
 	if p.peekTokenIs(token.Semicolon) {
@@ -158,6 +228,120 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	// Skip throw token
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	// Skip semicolon(;) token if exists
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LParen) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RParen) {
+		return nil
+	}
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	// Skip semicolon(;) token if exists
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseForInStatement() ast.Statement {
+	stmt := &ast.ForInStatement{Token: p.curToken}
+	if !p.expectPeek(token.LParen) {
+		return nil
+	}
+	if !p.expectPeek(token.Ident) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.In) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RParen) {
+		return nil
+	}
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	// Skip semicolon(;) token if exists
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth += 1
+	defer func() { p.loopDepth -= 1 }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		p.addError(tok.Position, fmt.Sprintf("%s outside of loop", tok.Literal))
+		return nil
+	}
+
+	// Skip semicolon(;) token if exists
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	if tok.Kind == token.Break {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if p.blockDepth > 0 {
+		p.addError(p.curToken.Position, "export is only allowed at the top level")
+		return nil
+	}
+
+	if !p.peekTokenIs(token.Let) && !p.peekTokenIs(token.Const) {
+		p.peekError(token.Let)
+		return nil
+	}
+	p.nextToken()
+
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -193,10 +377,57 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+// Report the problem found by the lexer.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.addError(p.curToken.Position, p.curToken.Literal)
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = p.appendStringPart(str.Parts)
+
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.StringMiddle) || p.curTokenIs(token.StringTail) {
+			p.addError(p.curToken.Position, "empty expression in string interpolation")
+			return nil
+		}
+
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+
+		switch {
+		case p.peekTokenIs(token.StringMiddle):
+			p.nextToken()
+			str.Parts = p.appendStringPart(str.Parts)
+		case p.peekTokenIs(token.StringTail):
+			p.nextToken()
+			str.Parts = p.appendStringPart(str.Parts)
+			return str
+		default:
+			msg := fmt.Sprintf("expected } to close string interpolation, got %s instead", p.peekToken.Kind)
+			p.addError(p.peekToken.Position, msg)
+			return nil
+		}
+	}
+}
+
+// Append the literal part of the current string token unless it is empty.
+func (p *Parser) appendStringPart(parts []ast.Expression) []ast.Expression {
+	if p.curToken.Literal == "" {
+		return parts
+	}
+	return append(parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -206,8 +437,27 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
+		if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = bigValue
+			return lit
+		}
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Position, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken.Position, msg)
 		return nil
 	}
 
@@ -228,7 +478,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashLiteralPair{}
 
 	for !p.peekTokenIs(token.RBrace) {
 		// Skip rbrace('{') or comma(',') token
@@ -241,7 +491,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		// Skip rbrace(':') token
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashLiteralPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBrace) && !p.expectPeek(token.Comma) {
 			return nil
@@ -268,6 +518,8 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
+	p.blockDepth += 1
+	defer func() { p.blockDepth -= 1 }()
 
 	// Skip lbrace('{') token
 	p.nextToken()
@@ -308,6 +560,28 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		msg := fmt.Sprintf("invalid target of %s: only identifiers and index expressions can be assigned", p.curToken.Kind)
+		p.addError(p.curToken.Position, msg)
+		return nil
+	}
+
+	p.nextToken()
+	// Assignment is right-associative: `a = b = c` is `a = (b = c)`
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 	if !p.expectPeek(token.LParen) {
@@ -335,6 +609,55 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.Catch) {
+		p.nextToken()
+		if !p.expectPeek(token.LParen) {
+			return nil
+		}
+		if !p.expectPeek(token.Ident) {
+			return nil
+		}
+		expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RParen) {
+			return nil
+		}
+		if !p.expectPeek(token.LBrace) {
+			return nil
+		}
+		expression.CatchBlock = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.Finally) {
+		p.nextToken()
+		if !p.expectPeek(token.LBrace) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.CatchBlock == nil && expression.Finally == nil {
+		p.addError(p.peekToken.Position, "expected catch or finally after try block")
+		return nil
+	}
+	return expression
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	expression := &ast.ImportExpression{Token: p.curToken}
+
+	p.nextToken()
+	expression.Path = p.parseExpression(PREFIX)
+
+	return expression
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
@@ -342,47 +665,82 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 	if !p.expectPeek(token.LBrace) {
 		return nil
 	}
+
+	// `break` and `continue` cannot jump out of the function
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// Parse `(a, b = 10, ...rest)` into `Parameters`, `Defaults` and `Rest` of `lit`.
+// Return false if it fails.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.Defaults = []ast.Expression{}
 
 	if p.peekTokenIs(token.RParen) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	for {
+		if p.peekTokenIs(token.Ellipsis) {
+			p.nextToken()
+			if !p.expectPeek(token.Ident) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if p.peekTokenIs(token.Comma) {
+				p.addError(p.peekToken.Position, "rest parameter must be last")
+				return false
+			}
+			break
+		}
 
-	for p.peekTokenIs(token.Comma) {
-		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.Ident) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
 
-	if !p.expectPeek(token.RParen) {
-		return nil
+		var defaultValue ast.Expression
+		if p.peekTokenIs(token.Assign) {
+			p.nextToken()
+			p.nextToken()
+			// Stop before `=` so that `b = c = 1` is rejected
+			defaultValue = p.parseExpression(ASSIGN)
+		} else if n := len(lit.Defaults); n > 0 && lit.Defaults[n-1] != nil {
+			p.addError(ident.Pos(), fmt.Sprintf("parameter without default follows parameter with default: %s", ident.Value))
+			return false
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.Defaults = append(lit.Defaults, defaultValue)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
 	}
 
-	return identifiers
+	return p.expectPeek(token.RParen)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RParen)
+	exp.Arguments = p.parseCallArguments()
 	return exp
 }
 
-func (p *Parser) parseExpressionList(end token.TokenKind) []ast.Expression {
+// Parse arguments of a call. They may be spread by `...` (e.g. `f(a, ...rest)`).
+func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
 	if p.peekTokenIs(token.RParen) {
@@ -390,6 +748,37 @@ func (p *Parser) parseExpressionList(end token.TokenKind) []ast.Expression {
 		return args
 	}
 
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.Ellipsis) {
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			args = append(args, spread)
+		} else {
+			args = append(args, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RParen) {
+		return nil
+	}
+
+	return args
+}
+
+func (p *Parser) parseExpressionList(end token.TokenKind) []ast.Expression {
+	args := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return args
+	}
+
 	p.nextToken()
 	args = append(args, p.parseExpression(LOWEST))
 	for p.peekTokenIs(token.Comma) {
@@ -404,11 +793,28 @@ func (p *Parser) parseExpressionList(end token.TokenKind) []ast.Expression {
 	return args
 }
 
+// Parse `left[index]` or `left[start:end]`.
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
 
+	var start ast.Expression
+	if !p.curTokenIs(token.Colon) {
+		start = p.parseExpression(LOWEST)
+		if !p.peekTokenIs(token.Colon) {
+			if !p.expectPeek(token.RBracket) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: start}
+		}
+		p.nextToken()
+	}
+
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+	if !p.peekTokenIs(token.RBracket) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 	if !p.expectPeek(token.RBracket) {
 		return nil
 	}
@@ -455,17 +861,30 @@ func (p *Parser) peekPrecedence() int {
 
 // Return errors detected while parsing.
 func (p *Parser) Errors() []string {
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Error()
+	}
+	return messages
+}
+
+// Return errors detected while parsing with their positions.
+func (p *Parser) DetailedErrors() []*Error {
 	return p.errors
 }
 
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, &Error{Pos: pos, Message: msg})
+}
+
 func (p *Parser) peekError(t token.TokenKind) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead (L%d)", t, p.peekToken.Kind, p.peekToken.Line)
-	p.errors = append(p.errors, msg)
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Kind)
+	p.addError(p.peekToken.Position, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenKind) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Position, msg)
 }
 
 func (p *Parser) registerPrefix(tokenKind token.TokenKind, fn prefixParseFn) {
@@ -43,6 +43,59 @@ let foobar = 334334;`
 	}
 }
 
+func TestConstStatement(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, "const x = 5;")
+	if !a.Equal(len(program.Statements), 1) {
+		return
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !a.True(ok, "*ast.LetStatement") {
+		return
+	}
+	a.Equal(stmt.TokenLiteral(), "const")
+	a.Equal(stmt.Name.Value, "x")
+	testLiteralExpression(a, stmt.Value, 5)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x += 1 + 2", "(x += (1 + 2))"},
+		{"x -= 1", "(x -= 1)"},
+		{"x *= 2", "(x *= 2)"},
+		{"x /= 2", "(x /= 2)"},
+		{"x = y = 3", "(x = (y = 3))"},
+		{"arr[1] = a == b", "((arr[1]) = (a == b))"},
+		{`h["k"] += 1`, "((h[k]) += 1)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(a, tt.input)
+		if !a.Equal(len(program.Statements), 1) {
+			continue
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !a.True(ok, "*ast.ExpressionStatement") {
+			continue
+		}
+		_, ok = stmt.Expression.(*ast.AssignExpression)
+		if !a.True(ok, "*ast.AssignExpression") {
+			continue
+		}
+		a.Equal(tt.expected, program.String())
+	}
+
+	p := New(lexer.New("1 = 2"))
+	p.ParseProgram()
+	a.Equal([]string{"1:3: invalid target of =: only identifiers and index expressions can be assigned"}, p.Errors())
+}
+
 func TestReturnStatements(t *testing.T) {
 	a := assert.New(t)
 
@@ -72,6 +125,120 @@ return 993322;`
 	}
 }
 
+func TestThrowStatement(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, `throw "oops";`)
+	if !a.Equal(len(program.Statements), 1) {
+		return
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !a.True(ok, "*ast.ThrowStatement") {
+		return
+	}
+	a.Equal(stmt.TokenLiteral(), "throw")
+	a.Equal(stmt.Value.String(), "oops")
+}
+
+func TestTryExpression(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x } catch (e) { y }", "try x catch(e) y"},
+		{"try { x } finally { z }", "try x finally z"},
+		{"try { x } catch (e) { y } finally { z }", "try x catch(e) y finally z"},
+	}
+
+	for _, tt := range tests {
+		program := parse(a, tt.input)
+		if !a.Equal(len(program.Statements), 1) {
+			continue
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !a.True(ok, "*ast.ExpressionStatement") {
+			continue
+		}
+		_, ok = stmt.Expression.(*ast.TryExpression)
+		if !a.True(ok, "*ast.TryExpression") {
+			continue
+		}
+		a.Equal(tt.expected, program.String())
+	}
+
+	p := New(lexer.New("try { x }"))
+	p.ParseProgram()
+	a.Equal([]string{"1:10: expected catch or finally after try block"}, p.Errors())
+}
+
+func TestLoopStatements(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x }", "while(x < 10) x"},
+		{"for (x in xs) { puts(x) }", "for(x in xs) puts(x)"},
+		{"while (true) { break; }", "whiletrue break;"},
+		{"for (x in xs) { if (x) { continue } }", "for(x in xs) ifx continue;"},
+	}
+
+	for _, tt := range tests {
+		program := parse(a, tt.input)
+		if !a.Equal(len(program.Statements), 1) {
+			continue
+		}
+		a.Equal(tt.expected, program.String())
+	}
+
+	_, ok := parse(a, "while (true) { 1 }").Statements[0].(*ast.WhileStatement)
+	a.True(ok, "*ast.WhileStatement")
+	forIn, ok := parse(a, "for (x in [1]) { 1 }").Statements[0].(*ast.ForInStatement)
+	if a.True(ok, "*ast.ForInStatement") {
+		a.Equal("x", forIn.Variable.Value)
+	}
+}
+
+func TestLoopControlOutsideOfLoop(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"if (true) { continue }", "1:13: continue outside of loop"},
+		{"while (true) { fn() { break } }", "1:23: break outside of loop"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if a.NotEmpty(p.Errors()) {
+			a.Equal(tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, `let lib = import "lib.mk"; export let x = 1; export const y = 2;`)
+	if !a.Equal(len(program.Statements), 3) {
+		return
+	}
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if a.True(ok, "*ast.LetStatement") {
+		_, ok = let.Value.(*ast.ImportExpression)
+		a.True(ok, "*ast.ImportExpression")
+	}
+	for _, stmt := range program.Statements[1:] {
+		_, ok := stmt.(*ast.ExportStatement)
+		a.True(ok, "*ast.ExportStatement")
+	}
+	a.Equal("let lib = import lib.mk;export let x = 1;export const y = 2;", program.String())
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	a := assert.New(t)
 
@@ -107,11 +274,28 @@ func TestParsingHashLiteral(t *testing.T) {
 	if !a.True(ok) {
 		return
 	}
-	_, ok = stmt.Expression.(*ast.HashLiteral)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
 	if !a.True(ok) {
 		return
 	}
-	// a.Equal(hash.Pairs[&ast.StringLiteral{Value: "one"}].String(), "0 + 1")
+	if !a.Equal(3, len(hash.Pairs)) {
+		return
+	}
+
+	// Pairs keep the source order
+	expected := []struct {
+		key   string
+		value string
+	}{
+		{"one", "(0 + 1)"},
+		{"two", "(10 - 8)"},
+		{"three", "(15 / 5)"},
+	}
+	for i, pair := range hash.Pairs {
+		testStringLiteral(a, pair.Key, expected[i].key)
+		a.Equal(expected[i].value, pair.Value.String())
+	}
+	a.Equal(`{one:(0 + 1), two:(10 - 8), three:(15 / 5)}`, hash.String())
 }
 
 func TestParsingIndexExpressions(t *testing.T) {
@@ -133,6 +317,35 @@ func TestParsingIndexExpressions(t *testing.T) {
 	testLiteralExpression(a, ie.Index, 2)
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"s[1:3]", "(s[1:3])"},
+		{"s[:n + 1]", "(s[:(n + 1)])"},
+		{"s[i:]", "(s[i:])"},
+		{"s[:]", "(s[:])"},
+		{"f(x)[1:][0]", "((f(x)[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		program := parse(a, tt.input)
+		a.Equal(tt.expected, program.String())
+	}
+
+	program := parse(a, "s[1:]")
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	se, ok := stmt.Expression.(*ast.SliceExpression)
+	if !a.True(ok) {
+		return
+	}
+	testIdendifier(a, se.Left, "s")
+	testLiteralExpression(a, se.Start, 1)
+	a.Nil(se.End)
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	a := assert.New(t)
 	input := `"Hello World";`
@@ -168,6 +381,78 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testLiteralExpression(a, stmt.Expression, 334)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, "100000000000000000000;")
+	if !a.Equal(len(program.Statements), 1) {
+		return
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !a.True(ok, "*ast.ExpressionStatement") {
+		return
+	}
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !a.True(ok, "*ast.IntegerLiteral") {
+		return
+	}
+	if a.NotNil(literal.Big) {
+		a.Equal("100000000000000000000", literal.Big.String())
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, `"total: ${sum(xs)} items, ${a + b}"`)
+	if !a.Equal(len(program.Statements), 1) {
+		return
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !a.True(ok, "*ast.ExpressionStatement") {
+		return
+	}
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !a.True(ok, "*ast.InterpolatedString") {
+		return
+	}
+	if !a.Equal(4, len(str.Parts)) {
+		return
+	}
+	testStringLiteral(a, str.Parts[0], "total: ")
+	a.Equal("sum(xs)", str.Parts[1].String())
+	testStringLiteral(a, str.Parts[2], " items, ")
+	a.Equal("(a + b)", str.Parts[3].String())
+	a.Equal("total: ${sum(xs)} items, ${(a + b)}", str.String())
+}
+
+func testStringLiteral(a *assert.Assertions, exp ast.Expression, value string) bool {
+	str, ok := exp.(*ast.StringLiteral)
+	if !a.True(ok, "*ast.StringLiteral") {
+		return false
+	}
+	return a.Equal(value, str.Value)
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, "2.5e3;")
+	if !a.Equal(len(program.Statements), 1) {
+		return
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !a.True(ok, "*ast.ExpressionStatement") {
+		return
+	}
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !a.True(ok, "*ast.FloatLiteral") {
+		return
+	}
+	a.Equal(2500.0, literal.Value)
+	a.Equal("2.5e3", literal.TokenLiteral())
+}
+
 func TestPrefixExpressions(t *testing.T) {
 	a := assert.New(t)
 	tests := []struct {
@@ -288,6 +573,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"(5 + 5) * 2 * (5 + 5)", "(((5 + 5) * 2) * (5 + 5))"},
 		{"-(5 + 5)", "(-(5 + 5))"},
 		{"!(true == true)", "(!(true == true))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a <= b == b >= a", "((a <= b) == (b >= a))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"x = a || b", "(x = (a || b))"},
+		{"a | b ^ c & d", "((a | b) ^ (c & d))"},
+		{"a + b << c", "(a + (b << c))"},
+		{"a & b == c", "((a & b) == c)"},
+		{"1 << 2 >> 3", "((1 << 2) >> 3)"},
+		{"-a >> b", "((-a) >> b)"},
 	}
 
 	for _, tt := range tests {
@@ -366,6 +663,60 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	a.Equal(function.Body.Statements[0].String(), "x + y")
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input          string
+		expected       string
+		expectedParams []string
+		expectedRest   string
+	}{
+		{"fn() { 1 }", "fn() 1", []string{}, ""},
+		{"fn(a, b = 10) { a }", "fn(a, b = 10) a", []string{"a", "b"}, ""},
+		{"fn(a = 1 + 2, b = a * 2) { a }", "fn(a = (1 + 2), b = (a * 2)) a", []string{"a", "b"}, ""},
+		{"fn(first, ...rest) { rest }", "fn(first, ...rest) rest", []string{"first"}, "rest"},
+		{"fn(...args) { args }", "fn(...args) args", []string{}, "args"},
+		{"fn(a, b = [], ...c) { c }", "fn(a, b = [], ...c) c", []string{"a", "b"}, "c"},
+	}
+
+	for _, tt := range tests {
+		program := parse(a, tt.input)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !a.True(ok, tt.input) {
+			continue
+		}
+		a.Equal(tt.expected, function.String())
+
+		params := []string{}
+		for _, p := range function.Parameters {
+			params = append(params, p.Value)
+		}
+		a.Equal(tt.expectedParams, params)
+		a.Len(function.Defaults, len(function.Parameters))
+		if tt.expectedRest == "" {
+			a.Nil(function.Rest)
+		} else if a.NotNil(function.Rest) {
+			a.Equal(tt.expectedRest, function.Rest.Value)
+		}
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	a := assert.New(t)
+	program := parse(a, "f(1, ...xs, ...[2, 3], g(...ys))")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !a.True(ok) {
+		return
+	}
+	a.Len(call.Arguments, 4)
+	_, ok = call.Arguments[1].(*ast.SpreadExpression)
+	a.True(ok)
+	a.Equal("f(1, ...xs, ...[2, 3], g(...ys))", call.String())
+}
+
 func parse(a *assert.Assertions, input string) *ast.Program {
 	l := lexer.New(input)
 	p := New(l)
@@ -444,3 +795,36 @@ func testBooleanLiteral(a *assert.Assertions, exp ast.Expression, value bool) bo
 	}
 	return true
 }
+
+func TestParseErrors(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "1:7: expected next token to be =, got INT instead"},
+		{"let x = 5;\n}", "2:1: no prefix parse function for } found"},
+		{"\n\n  let 5;", "3:7: expected next token to be IDENT, got INT instead"},
+		{`let s = "abc`, "1:9: unterminated string"},
+		{`let s = "a\qc";`, "1:11: invalid escape sequence \\q"},
+		{"let s = 1 @ 2;", "1:11: unexpected character '@'"},
+		{`"a${}b"`, "1:5: empty expression in string interpolation"},
+		{`"a${x y}b"`, "1:7: expected } to close string interpolation, got IDENT instead"},
+		{`"a${x`, "1:6: expected } to close string interpolation, got EOF instead"},
+		{"fn(a = 1, b) {}", "1:11: parameter without default follows parameter with default: b"},
+		{"fn(...a, b) {}", "1:8: rest parameter must be last"},
+		{"fn(a, 1) {}", "1:7: expected next token to be IDENT, got INT instead"},
+		{"fn(...) {}", "1:7: expected next token to be IDENT, got ) instead"},
+		{"fn(a = b = 1) {}", "1:10: expected next token to be ), got = instead"},
+		{"[...xs]", "1:2: no prefix parse function for ... found"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if !a.NotEmpty(p.Errors()) {
+			continue
+		}
+		a.Equal(tt.expected, p.Errors()[0])
+	}
+}
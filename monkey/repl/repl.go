@@ -2,43 +2,62 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
 	"os"
 
+	"monkey/ast"
+	"monkey/compiler"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// The prompt shown while reading the rest of an incomplete input
+const CONTINUATION_PROMPT = ".. "
+
+// Names of the execution engines
+const (
+	// Tree-walking evaluator
+	EngineEval = "eval"
+	// Bytecode compiler and virtual machine
+	EngineVM = "vm"
+)
+
+func Start(in io.Reader, out io.Writer, engine string) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	run := NewRunner(engine, nil)
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, ok := readInput(scanner, out)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.New(input)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+			printParseErrors(out, input, p.DetailedErrors())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated, err := run(program)
+		if err != nil {
+			io.WriteString(out, "Error: "+err.Error()+"\n")
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
+			if errObj, ok := evaluated.(*object.Error); ok {
+				printErrorDetails(out, input, errObj)
+			}
 			if ex, ok := evaluated.(*object.Exit); ok {
 				os.Exit(ex.Status)
 			}
@@ -46,8 +65,118 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// Read lines until they form a complete input.
+// It returns false if the input ends before any line is read.
+func readInput(scanner *bufio.Scanner, out io.Writer) (string, bool) {
+	io.WriteString(out, PROMPT)
+	if !scanner.Scan() {
+		return "", false
+	}
+
+	input := scanner.Text()
+	for isIncomplete(input) {
+		io.WriteString(out, CONTINUATION_PROMPT)
+		if !scanner.Scan() {
+			// Let the parser report the error of the incomplete input
+			break
+		}
+		input += "\n" + scanner.Text()
+	}
+	return input, true
+}
+
+// Judge if `input` needs more lines, i.e. it has unclosed brackets or raw strings, or ends with an operator.
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	last := token.Eof
+
+	for tok := l.NextToken(); tok.Kind != token.Eof; tok = l.NextToken() {
+		switch tok.Kind {
+		case token.LParen, token.LBrace, token.LBracket, token.StringHead:
+			depth += 1
+		case token.RParen, token.RBrace, token.RBracket, token.StringTail:
+			depth -= 1
+		case token.Illegal:
+			if tok.Literal == "unterminated raw string" {
+				return true
+			}
+		}
+		last = tok.Kind
+	}
+
+	if depth > 0 {
+		return true
+	}
+
+	switch last {
+	case token.Assign, token.PlusAssign, token.MinusAssign, token.AsteriskAssign, token.SlashAssign, token.Plus, token.Minus, token.Bang, token.Asterisk, token.Slash,
+		token.Percent, token.Lt, token.Gt, token.Le, token.Ge, token.Eq, token.Ne, token.And, token.Or,
+		token.BitAnd, token.BitOr, token.BitXor, token.Shl, token.Shr, token.Comma, token.Colon,
+		token.Function, token.Let, token.Const, token.If, token.Else, token.Return,
+		token.Throw, token.Try, token.Catch, token.Finally, token.While, token.For, token.In,
+		token.Import, token.Export:
+		return true
+	}
+	return false
+}
+
+// Execute a program with keeping the state (e.g. global variables) between calls.
+type Runner func(program *ast.Program) (object.Object, error)
+
+// Create a runner of `engine` whose global scope is initialized with `bindings`.
+func NewRunner(engine string, bindings map[string]object.Object) Runner {
+	if engine == EngineVM {
+		constants := []object.Object{}
+		globals := make([]object.Object, vm.GlobalsSize)
+		runtime := object.NewRuntime()
+		symbolTable := compiler.NewGlobalSymbolTable()
+		for name, value := range bindings {
+			symbol := symbolTable.Define(name)
+			globals[symbol.Index] = value
+		}
+
+		return func(program *ast.Program) (object.Object, error) {
+			comp := compiler.NewWithState(symbolTable, constants)
+			if err := comp.Compile(program); err != nil {
+				return nil, err
+			}
+
+			bytecode := comp.Bytecode()
+			constants = bytecode.Constants
+
+			machine := vm.NewWithRuntime(bytecode, globals, runtime)
+			if err := machine.Run(); err != nil {
+				return nil, err
+			}
+			return machine.Result(), nil
+		}
+	}
+
+	env := object.NewEnvironment()
+	for name, value := range bindings {
+		env.Set(name, value)
+	}
+	return func(program *ast.Program) (object.Object, error) {
+		return evaluator.Eval(program, env), nil
+	}
+}
+
+// Print where the error occurred and the stack trace if any.
+func printErrorDetails(out io.Writer, input string, errObj *object.Error) {
+	if !errObj.Pos.IsValid() {
+		return
+	}
+
+	io.WriteString(out, errObj.Pos.Annotate(input)+"\n")
+	if len(errObj.Stack) > 0 {
+		io.WriteString(out, "\n"+errObj.StackTrace())
+	}
+}
+
+func printParseErrors(out io.Writer, input string, errors []*parser.Error) {
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+		io.WriteString(out, err.Pos.Annotate(input)+"\n")
 	}
 }
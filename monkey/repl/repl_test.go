@@ -0,0 +1,54 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n x + y", true},
+		{"let add = fn(x, y) {\n x + y\n};", false},
+		{"[1, 2,", true},
+		{"add(1,", true},
+		{"1 +", true},
+		{"let x =", true},
+		{"if (x) { 1 } else", true},
+		{"}", false},
+		{"let s = `first line", true},
+		{"let s = `first line\nsecond line`", false},
+		{`let s = "unterminated`, false},
+		{`"a ${`, true},
+		{`"a ${x} b"`, false},
+	}
+
+	for _, tt := range tests {
+		a.Equal(tt.expected, isIncomplete(tt.input), tt.input)
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	a := assert.New(t)
+	input := strings.Join([]string{
+		"let add = fn(x, y) {",
+		"  x +",
+		"  y",
+		"};",
+		"add(1, 2)",
+	}, "\n")
+
+	for _, engine := range []string{EngineEval, EngineVM} {
+		var out bytes.Buffer
+		Start(strings.NewReader(input), &out, engine)
+		a.Equal(">> .. .. .. null\n>> 3\n>> ", out.String(), engine)
+	}
+}
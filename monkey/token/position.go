@@ -0,0 +1,59 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A location in a source code
+type Position struct {
+	Filename string
+	// Byte offset from the beginning of the source (0-based)
+	Offset int
+	// Line number (1-based)
+	Line int
+	// Column number counted in characters (1-based)
+	Column int
+}
+
+// Judge if the position points to somewhere in a source code.
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// Return "<file>:<line>:<column>". The file name is omitted if it is unknown.
+func (pos Position) String() string {
+	s := fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	if pos.Filename != "" {
+		s = pos.Filename + ":" + s
+	}
+	return s
+}
+
+// Return the source line of the position and a caret pointing to the column, e.g.
+//
+//	let x = 5 + true;
+//	          ^
+func (pos Position) Annotate(source string) string {
+	lines := strings.Split(source, "\n")
+	if !pos.IsValid() || pos.Line > len(lines) {
+		return ""
+	}
+
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+	var caret strings.Builder
+	for i, ch := range []rune(line) {
+		if i >= pos.Column-1 {
+			break
+		}
+		// Keep tabs to align the caret with the source line
+		if ch == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+
+	return line + "\n" + caret.String()
+}
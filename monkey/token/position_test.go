@@ -0,0 +1,17 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionAnnotate(t *testing.T) {
+	a := assert.New(t)
+	source := "let x = 5;\n\tx + true;\n"
+
+	a.Equal("let x = 5;\n        ^", Position{Line: 1, Column: 9}.Annotate(source))
+	a.Equal("\tx + true;\n\t  ^", Position{Line: 2, Column: 4}.Annotate(source))
+	a.Equal("", Position{}.Annotate(source))
+	a.Equal("a.mk:2:4", Position{Filename: "a.mk", Line: 2, Column: 4}.String())
+}
@@ -10,28 +10,51 @@ type TokenKind int
 
 // Enumeration constants for `TokenKind`
 const (
+	// A malformed token. Its literal describes what is wrong.
 	Illegal TokenKind = iota
 	Eof
 
 	Ident
 	Int
+	Float
 	String
+	// Parts of an interpolated string "<head>${...}<middle>${...}<tail>"
+	StringHead
+	StringMiddle
+	StringTail
 
 	Assign
+	PlusAssign
+	MinusAssign
+	AsteriskAssign
+	SlashAssign
 	Plus
 	Minus
 	Bang
 	Asterisk
 	Slash
+	Percent
 
 	Lt
 	Gt
+	Le
+	Ge
 	Eq
 	Ne
 
+	And
+	Or
+
+	BitAnd
+	BitOr
+	BitXor
+	Shl
+	Shr
+
 	Comma
 	Colon
 	Semicolon
+	Ellipsis
 
 	LParen
 	RParen
@@ -42,11 +65,23 @@ const (
 
 	Function
 	Let
+	Const
 	True
 	False
 	If
 	Else
 	Return
+	Throw
+	Try
+	Catch
+	Finally
+	While
+	For
+	In
+	Break
+	Continue
+	Import
+	Export
 )
 
 func (tt TokenKind) String() string {
@@ -59,10 +94,26 @@ func (tt TokenKind) String() string {
 		return "IDENT"
 	case Int:
 		return "INT"
+	case Float:
+		return "FLOAT"
 	case String:
 		return "STRING"
+	case StringHead:
+		return "STRING_HEAD"
+	case StringMiddle:
+		return "STRING_MIDDLE"
+	case StringTail:
+		return "STRING_TAIL"
 	case Assign:
 		return "="
+	case PlusAssign:
+		return "+="
+	case MinusAssign:
+		return "-="
+	case AsteriskAssign:
+		return "*="
+	case SlashAssign:
+		return "/="
 	case Plus:
 		return "+"
 	case Minus:
@@ -73,20 +124,42 @@ func (tt TokenKind) String() string {
 		return "*"
 	case Slash:
 		return "/"
+	case Percent:
+		return "%"
 	case Lt:
 		return "<"
 	case Gt:
 		return ">"
+	case Le:
+		return "<="
+	case Ge:
+		return ">="
 	case Eq:
 		return "=="
 	case Ne:
 		return "!="
+	case And:
+		return "&&"
+	case Or:
+		return "||"
+	case BitAnd:
+		return "&"
+	case BitOr:
+		return "|"
+	case BitXor:
+		return "^"
+	case Shl:
+		return "<<"
+	case Shr:
+		return ">>"
 	case Comma:
 		return ","
 	case Colon:
 		return ":"
 	case Semicolon:
 		return ";"
+	case Ellipsis:
+		return "..."
 	case LParen:
 		return "("
 	case RParen:
@@ -103,6 +176,8 @@ func (tt TokenKind) String() string {
 		return "FUNCTION"
 	case Let:
 		return "LET"
+	case Const:
+		return "CONST"
 	case True:
 		return "TRUE"
 	case False:
@@ -113,19 +188,53 @@ func (tt TokenKind) String() string {
 		return "ELSE"
 	case Return:
 		return "RETURN"
+	case Throw:
+		return "THROW"
+	case Try:
+		return "TRY"
+	case Catch:
+		return "CATCH"
+	case Finally:
+		return "FINALLY"
+	case While:
+		return "WHILE"
+	case For:
+		return "FOR"
+	case In:
+		return "IN"
+	case Break:
+		return "BREAK"
+	case Continue:
+		return "CONTINUE"
+	case Import:
+		return "IMPORT"
+	case Export:
+		return "EXPORT"
 	default:
 		return fmt.Sprintf("%d", int(tt))
 	}
 }
 
 var keywards = map[string]TokenKind{
-	"fn":     Function,
-	"let":    Let,
-	"true":   True,
-	"false":  False,
-	"if":     If,
-	"else":   Else,
-	"return": Return,
+	"fn":       Function,
+	"let":      Let,
+	"const":    Const,
+	"true":     True,
+	"false":    False,
+	"if":       If,
+	"else":     Else,
+	"return":   Return,
+	"throw":    Throw,
+	"try":      Try,
+	"catch":    Catch,
+	"finally":  Finally,
+	"while":    While,
+	"for":      For,
+	"in":       In,
+	"break":    Break,
+	"continue": Continue,
+	"import":   Import,
+	"export":   Export,
 }
 
 // Judge if the argument is a keyword or not.
@@ -141,5 +250,6 @@ func LookUpIdent(ident string) TokenKind {
 type Token struct {
 	Kind    TokenKind
 	Literal string
-	Line    int
+	// Where the token starts
+	Position
 }
@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"testing"
+
+	"monkey/compiler"
+	"monkey/enginetest"
+	"monkey/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineCases(t *testing.T) {
+	enginetest.Run(t, func(input string) (object.Object, error) {
+		comp := compiler.New()
+		if err := comp.Compile(parse(assert.New(t), input)); err != nil {
+			return nil, err
+		}
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			return nil, err
+		}
+		return vm.Result(), nil
+	})
+}
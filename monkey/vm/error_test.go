@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"testing"
+
+	"monkey/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorPosition(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"let x = 1;\nlet y = -true;", 2, 9},
+		{"let f = fn() {\n  1 + true\n};\nf()", 2, 5},
+		{"let f = fn(x) { x };\nf(1, 2)", 2, 2},
+		{`len(1)`, 1, 4},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := runVM(a, tt.input).(*object.Error)
+		if !a.True(ok, tt.input) {
+			continue
+		}
+
+		a.Equal(tt.expectedLine, errObj.Pos.Line, tt.input)
+		a.Equal(tt.expectedColumn, errObj.Pos.Column, tt.input)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	a := assert.New(t)
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(x) {
+  let r = inner(x);
+  r
+};
+outer(1);`
+
+	errObj, ok := runVM(a, input).(*object.Error)
+	if !a.True(ok) {
+		return
+	}
+
+	a.Equal(object.TYPE_ERROR, errObj.ErrorKind)
+	a.Equal(2, errObj.Pos.Line)
+	a.Equal(5, errObj.Pos.Column)
+	if !a.Len(errObj.Stack, 2) {
+		return
+	}
+	a.Equal("inner", errObj.Stack[0].Function)
+	a.Equal(5, errObj.Stack[0].CallSite.Line)
+	a.Equal(11, errObj.Stack[0].CallSite.Column)
+	a.Equal("outer", errObj.Stack[1].Function)
+	a.Equal(8, errObj.Stack[1].CallSite.Line)
+	a.Equal(1, errObj.Stack[1].CallSite.Column)
+}
@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+// An activation record of a function call.
+type Frame struct {
+	cl *object.Closure
+	// Instruction pointer
+	ip int
+	// Stack pointer before the call. Locals are stored from here.
+	basePointer int
+	// The number of passed arguments. Parameters after them take the default values.
+	numArgs int
+}
+
+func NewFrame(cl *object.Closure, basePointer int, numArgs int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer, numArgs: numArgs}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
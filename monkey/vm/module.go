@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+// Compile and run the program of a module with its own globals in the same runtime. Its closures keep
+// referring to the constants and the globals of the module when they are called by the importer.
+func (vm *VM) runModule(program *ast.Program) (object.Object, error) {
+	symbolTable := compiler.NewGlobalSymbolTable()
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	globals := make([]object.Object, GlobalsSize)
+	machine := NewWithRuntime(comp.Bytecode(), globals, vm.runtime)
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	switch result := machine.Result().(type) {
+	case *object.Error, *object.Exit:
+		return result, nil
+	}
+
+	return evaluator.ModuleExports(program, func(name string) object.Object {
+		symbol, _ := symbolTable.Resolve(name)
+		return deref(globals[symbol.Index])
+	}), nil
+}
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"lib/math.mk": `
+			let helper = fn(x) { x * 2 };
+			export let double = fn(x) { helper(x) };
+			export const answer = 42;`,
+		"lib/util.mk": `
+			let math = import "math.mk";
+			export let quadruple = fn(x) { math["double"](math["double"](x)) };`,
+		"main.mk": `
+			let math = import "lib/math.mk";
+			let util = import "lib/util.mk";
+			math["double"](util["quadruple"](1)) + math["answer"]`,
+	})
+
+	testIntegerObject(a, runFile(a, filepath.Join(dir, "main.mk")), 50)
+}
+
+func TestImportGlobals(t *testing.T) {
+	a := assert.New(t)
+	// Functions of a module keep using its globals, not those of the importer
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"counter.mk": `
+			let count = 0;
+			export let inc = fn() { count += 1; count };`,
+		"main.mk": `
+			let count = 100;
+			let counter = import "counter.mk";
+			counter["inc"]();
+			counter["inc"]() + count`,
+	})
+
+	testIntegerObject(a, runFile(a, filepath.Join(dir, "main.mk")), 102)
+}
+
+func TestImportCache(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"counter.mk": `export let state = [0];`,
+		"main.mk": `
+			let first = import "counter.mk";
+			first["state"][0] = 5;
+			let second = import "./counter.mk";
+			second["state"][0]`,
+		"read.mk": `(import "counter.mk")["state"][0]`,
+	})
+
+	testIntegerObject(a, runFile(a, filepath.Join(dir, "main.mk")), 5)
+	// Another VM has its own runtime, so it imports the module again
+	testIntegerObject(a, runFile(a, filepath.Join(dir, "read.mk")), 0)
+}
+
+func TestImportErrors(t *testing.T) {
+	a := assert.New(t)
+	dir := writeFiles(a, t.TempDir(), map[string]string{
+		"a.mk":          `import "b.mk"`,
+		"b.mk":          `import "a.mk"`,
+		"undefined.mk":  `x`,
+		"failing.mk":    `1 + true`,
+		"cycle.mk":      `import "a.mk"`,
+		"badpath.mk":    `import 1`,
+		"runtime.mk":    `import "failing.mk"`,
+		"uncompiled.mk": `import "undefined.mk"`,
+	})
+
+	tests := []struct {
+		file            string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{
+			"cycle.mk", object.IMPORT_ERROR,
+			"import cycle: " + filepath.Join(dir, "a.mk") + " -> " + filepath.Join(dir, "b.mk") + " -> " + filepath.Join(dir, "a.mk"),
+		},
+		{"badpath.mk", object.TYPE_ERROR, "import path must be STRING, got INTEGER"},
+		{"runtime.mk", object.TYPE_ERROR, "unknown operator: INTEGER + BOOLEAN"},
+		{
+			"uncompiled.mk", object.IMPORT_ERROR,
+			"cannot import " + `"` + filepath.Join(dir, "undefined.mk") + `"` + ": identifier not found: x",
+		},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := runFile(a, filepath.Join(dir, tt.file)).(*object.Error)
+		if !a.True(ok, tt.file) {
+			continue
+		}
+		a.Equal(tt.expectedKind, errObj.ErrorKind)
+		a.Equal(tt.expectedMessage, errObj.Message)
+	}
+}
+
+func writeFiles(a *assert.Assertions, dir string, files map[string]string) string {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		a.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		a.NoError(os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func runFile(a *assert.Assertions, path string) object.Object {
+	input, err := os.ReadFile(path)
+	if !a.NoError(err) {
+		return nil
+	}
+
+	p := parser.New(lexer.NewFile(path, string(input)))
+	program := p.ParseProgram()
+	if !a.Empty(p.Errors()) {
+		return nil
+	}
+
+	comp := compiler.New()
+	if !a.NoError(comp.Compile(program)) {
+		return nil
+	}
+	vm := New(comp.Bytecode())
+	if !a.NoError(vm.Run()) {
+		return nil
+	}
+	return vm.Result()
+}
@@ -0,0 +1,643 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+// A stack-based virtual machine which executes `compiler.Bytecode`.
+type VM struct {
+	builtins []*object.Builtin
+	// The state shared with the VMs of imported modules (e.g. the cache of modules)
+	runtime *object.Runtime
+
+	stack []object.Object
+	// Points to the next free slot. The top of the stack is `stack[sp-1]`.
+	sp int
+
+	frames      []*Frame
+	framesIndex int
+
+	// Handlers installed by `try` from the outermost one
+	handlers []handler
+
+	// The value of the program. It is the last popped element or
+	// the error/exit object which stopped the execution.
+	result object.Object
+	// A failure of the VM in a function called back from a builtin
+	err error
+}
+
+// Where an error raised in a `try` block is handled
+type handler struct {
+	// The position of the instructions which receive the error
+	ip int
+	// The frame and the stack pointer to restore
+	framesIndex int
+	sp          int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// Create a VM which shares globals with the previous execution (e.g. in REPL).
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	return NewWithRuntime(bytecode, s, object.NewRuntime())
+}
+
+// Create a VM which also shares `runtime` (e.g. imported modules) with other executions.
+func NewWithRuntime(bytecode *compiler.Bytecode, s []object.Object, runtime *object.Runtime) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+		CallSites:    bytecode.CallSites,
+	}
+	mainClosure := &object.Closure{Fn: mainFn, Constants: bytecode.Constants, Globals: s}
+	mainFrame := NewFrame(mainClosure, 0, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	names := evaluator.BuiltinNames()
+	builtins := make([]*object.Builtin, len(names))
+	for i, name := range names {
+		builtins[i], _ = evaluator.LookUpBuiltin(name)
+	}
+
+	return &VM{
+		builtins:    builtins,
+		runtime:     runtime,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// Return the value of the program after `Run`.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("frame overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex += 1
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex -= 1
+	return vm.frames[vm.framesIndex]
+}
+
+// Execute the bytecode. Errors of the Monkey program (e.g. `5 + true`) are not
+// reported as a Go error but stored in `Result`; the returned error only
+// indicates a failure of the VM itself.
+func (vm *VM) Run() error {
+	halt, err := vm.run(0)
+	if halt != nil {
+		vm.result = halt
+	}
+	return err
+}
+
+// Execute instructions while more than `baseFrames` frames remain.
+// Return the error or the exit object which stopped the execution.
+func (vm *VM) run(baseFrames int) (object.Object, error) {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > baseFrames && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip += 1
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err error
+		var halt object.Object
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.currentFrame().cl.Constants[constIndex])
+		case code.OpPop:
+			vm.result = vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+			halt, err = vm.pushResult(evaluator.EvalInfixExpression(infixOperators[op], left, right))
+		case code.OpMinus:
+			halt, err = vm.pushResult(evaluator.EvalPrefixExpression("-", vm.pop()))
+		case code.OpBang:
+			halt, err = vm.pushResult(evaluator.EvalPrefixExpression("!", vm.pop()))
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+		case code.OpDup:
+			n := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			top := vm.sp
+			for i := top - n; i < top && err == nil; i++ {
+				err = vm.push(vm.stack[i])
+			}
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpIfPassed:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if localIndex < vm.currentFrame().numArgs {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			assign(&vm.currentFrame().cl.Globals[globalIndex], vm.pop())
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(deref(vm.currentFrame().cl.Globals[globalIndex]))
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			assign(&vm.stack[frame.basePointer+int(localIndex)], vm.pop())
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			err = vm.push(deref(vm.stack[frame.basePointer+int(localIndex)]))
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.builtins[builtinIndex])
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(deref(vm.currentFrame().cl.Free[freeIndex]))
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			assign(&vm.currentFrame().cl.Free[freeIndex], vm.pop())
+		case code.OpCurrentClosure:
+			err = vm.push(vm.currentFrame().cl)
+		case code.OpCaptureGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(capture(&vm.currentFrame().cl.Globals[globalIndex]))
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(capture(&vm.stack[vm.currentFrame().basePointer+int(localIndex)]))
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+		case code.OpClearGlobals:
+			first := int(code.ReadUint16(ins[ip+1:]))
+			count := int(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+			clearSlots(vm.currentFrame().cl.Globals[first : first+count])
+		case code.OpClearLocals:
+			first := int(code.ReadUint8(ins[ip+1:]))
+			count := int(code.ReadUint8(ins[ip+2:]))
+			vm.currentFrame().ip += 2
+			start := vm.currentFrame().basePointer + first
+			clearSlots(vm.stack[start : start+count])
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			err = vm.push(array)
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := evaluator.Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+			err = vm.push(str)
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			halt, err = vm.pushResult(hash)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			halt, err = vm.pushResult(evaluator.EvalIndexExpression(left, index))
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			halt, err = vm.pushResult(evaluator.SetIndex(left, index, val))
+		case code.OpIterate:
+			items, errObj := evaluator.Iterate(vm.pop())
+			if errObj != nil {
+				halt = errObj
+				break
+			}
+			err = vm.push(&object.Iterator{Items: items})
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator := vm.stack[vm.sp-1].(*object.Iterator)
+			if iterator.Next >= len(iterator.Items) {
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			iterator.Next += 1
+			err = vm.push(iterator.Items[iterator.Next-1])
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+			halt, err = vm.pushResult(evaluator.EvalSlice(left, start, end))
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			halt, err = vm.executeCall(int(numArgs))
+		case code.OpCallSpread:
+			numArrays := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			halt, err = vm.executeSpreadCall(int(numArrays))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				// `return` in the main program stops the execution
+				halt = returnValue
+				break
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(evaluator.NULL)
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.pushClosure(int(constIndex), int(numFree))
+		case code.OpThrow:
+			halt = evaluator.Throw(vm.pop())
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			vm.handlers = append(vm.handlers, handler{ip: pos, framesIndex: vm.framesIndex, sp: vm.sp})
+		case code.OpPopTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpCatch:
+			// `exit` is not caught but runs `finally`
+			switch raised := vm.pop().(type) {
+			case *object.Error:
+				err = vm.push(evaluator.CaughtValue(raised))
+			default:
+				halt = raised
+			}
+		case code.OpRethrow:
+			halt = vm.pop()
+		case code.OpImport:
+			importer := vm.currentFrame().cl.Constants[code.ReadUint16(ins[ip+1:])].(*object.String)
+			vm.currentFrame().ip += 2
+			halt, err = vm.pushResult(evaluator.ImportModule(vm.pop(), importer.Value, vm.runtime, vm.runModule))
+		default:
+			err = fmt.Errorf("unknown opcode: %d", op)
+		}
+
+		// Errors and exits are passed to the handlers of `try` if any
+		switch raised := halt.(type) {
+		case *object.Error:
+			vm.traceError(raised)
+			halt, err = vm.unwind(raised)
+		case *object.Exit:
+			halt, err = vm.unwind(raised)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if halt != nil {
+			return halt, nil
+		}
+	}
+
+	return nil, nil
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+// Push the result of an operation. If it is an error or an exit,
+// it is returned instead to stop the execution.
+func (vm *VM) pushResult(obj object.Object) (object.Object, error) {
+	switch obj.(type) {
+	case *object.Error, *object.Exit:
+		return obj, nil
+	}
+	return nil, vm.push(obj)
+}
+
+// Pass a raised error (or exit) to the innermost handler of `try`.
+// It is returned back if there is no handler to stop the execution.
+func (vm *VM) unwind(raised object.Object) (object.Object, error) {
+	if len(vm.handlers) == 0 {
+		return raised, nil
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip - 1
+	return nil, vm.push(raised)
+}
+
+// Return the value of a variable, which is stored in a cell if closures captured it.
+func deref(obj object.Object) object.Object {
+	if cell, ok := obj.(*object.Cell); ok {
+		return cell.Value
+	}
+	return obj
+}
+
+// Store `val` into the slot of a variable, or into its cell if closures captured it.
+func assign(slot *object.Object, val object.Object) {
+	if cell, ok := (*slot).(*object.Cell); ok {
+		cell.Value = val
+		return
+	}
+	*slot = val
+}
+
+// Return the cell of the variable in `slot` to be captured by a closure.
+// The value is moved into a new cell when the variable is captured first.
+func capture(slot *object.Object) object.Object {
+	if _, ok := (*slot).(*object.Cell); !ok {
+		*slot = &object.Cell{Value: *slot}
+	}
+	return *slot
+}
+
+// Clear the slots of variables so that they are bound afresh.
+func clearSlots(slots []object.Object) {
+	for i := range slots {
+		slots[i] = nil
+	}
+}
+
+// Attach the position where the error occurred and the calls of the functions
+// which it propagates through, like the evaluator does.
+func (vm *VM) traceError(errObj *object.Error) {
+	if errObj.Pos.IsValid() {
+		return
+	}
+	frame := vm.currentFrame()
+	errObj.Pos = frame.cl.Fn.Positions.Lookup(frame.ip)
+	if !errObj.Pos.IsValid() {
+		return
+	}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		caller := vm.frames[i-1]
+		callSite := caller.cl.Fn.CallSites.Lookup(caller.ip)
+		errObj.Stack = append(errObj.Stack, object.StackFrame{Function: vm.frames[i].cl.Fn.Name, CallSite: callSite})
+	}
+}
+
+func (vm *VM) executeCall(numArgs int) (object.Object, error) {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		result := callee.Call(vm.callFunction, nil, args...)
+		if vm.err != nil {
+			return nil, vm.err
+		}
+		vm.sp = vm.sp - numArgs - 1
+		return vm.pushResult(result)
+	default:
+		message := fmt.Sprintf("not a function: %s", callee.Kind())
+		return &object.Error{ErrorKind: object.TYPE_ERROR, Message: message}, nil
+	}
+}
+
+// Concatenate the arrays on the stack into arguments and call the function below them.
+func (vm *VM) executeSpreadCall(numArrays int) (object.Object, error) {
+	arrays := make([]object.Object, numArrays)
+	copy(arrays, vm.stack[vm.sp-numArrays:vm.sp])
+	vm.sp -= numArrays
+
+	numArgs := 0
+	for _, arg := range arrays {
+		array, ok := arg.(*object.Array)
+		if !ok {
+			message := fmt.Sprintf("spread argument must be ARRAY, got %s", arg.Kind())
+			return &object.Error{ErrorKind: object.TYPE_ERROR, Message: message}, nil
+		}
+		for _, el := range array.Elements {
+			if err := vm.push(el); err != nil {
+				return nil, err
+			}
+		}
+		numArgs += len(array.Elements)
+	}
+	return vm.executeCall(numArgs)
+}
+
+// Call `fn` from builtins (e.g. the callback of `map`) and return its result.
+// A closure is executed on top of the current stack until it returns.
+func (vm *VM) callFunction(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Closure:
+		baseFrames, sp := vm.framesIndex, vm.sp
+		restore := func() {
+			vm.framesIndex, vm.sp = baseFrames, sp
+		}
+
+		for _, obj := range append([]object.Object{fn}, args...) {
+			if vm.err = vm.push(obj); vm.err != nil {
+				restore()
+				return evaluator.NULL
+			}
+		}
+		halt, err := vm.callClosure(fn, len(args))
+		if halt == nil && err == nil {
+			halt, err = vm.run(baseFrames)
+		}
+		if err != nil || halt != nil {
+			vm.err = err
+			restore()
+			if halt == nil {
+				return evaluator.NULL
+			}
+			return halt
+		}
+		return vm.pop()
+	case *object.Builtin:
+		return fn.Call(vm.callFunction, nil, args...)
+	default:
+		message := fmt.Sprintf("not a function: %s", fn.Kind())
+		return &object.Error{ErrorKind: object.TYPE_ERROR, Message: message}
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) (object.Object, error) {
+	fn := cl.Fn
+	max := fn.NumParameters
+	if fn.Variadic {
+		max = -1
+	}
+	if err := evaluator.CheckArity(numArgs, fn.NumParameters-fn.NumDefaults, max); err != nil {
+		return err, nil
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs, numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return nil, err
+	}
+	vm.sp = frame.basePointer + fn.NumLocals
+	if vm.sp >= StackSize {
+		return nil, fmt.Errorf("stack overflow")
+	}
+	var rest *object.Array
+	if fn.Variadic {
+		// Collect the extra arguments into the local next to the parameters
+		rest = &object.Array{Elements: []object.Object{}}
+		if numArgs > fn.NumParameters {
+			rest.Elements = append(rest.Elements, vm.stack[frame.basePointer+fn.NumParameters:frame.basePointer+numArgs]...)
+		}
+	}
+
+	// Clear the locals so that `let` does not store into a cell left by a previous call
+	numPassed := numArgs
+	if numPassed > fn.NumParameters {
+		numPassed = fn.NumParameters
+	}
+	for i := frame.basePointer + numPassed; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	if rest != nil {
+		vm.stack[frame.basePointer+fn.NumParameters] = rest
+	}
+
+	return nil, nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	current := vm.currentFrame().cl
+	constant := current.Constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free, Constants: current.Constants, Globals: current.Globals}
+	return vm.push(closure)
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	copy(elements, vm.stack[startIndex:endIndex])
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) object.Object {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		if !object.IsHashable(key) {
+			message := fmt.Sprintf("unusable as hash key: %s", key.Kind())
+			return &object.Error{ErrorKind: object.TYPE_ERROR, Message: message}
+		}
+		hash.Set(key, value)
+	}
+
+	return hash
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp += 1
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp -= 1
+	return o
+}
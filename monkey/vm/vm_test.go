@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{"let f = fn() { 1 }; f == f", true},
+	})
+}
+
+func TestErrorHandling(t *testing.T) {
+	a := assert.New(t)
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: CLOSURE"},
+		{"fn(a, b) { a + b }(1);", "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := runVM(a, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !a.True(ok, tt.input) {
+			continue
+		}
+		a.Equal(tt.expectedMessage, errObj.Message)
+	}
+}
+
+func TestUndefinedIdentifier(t *testing.T) {
+	a := assert.New(t)
+
+	program := parse(a, "foobar")
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if !a.Error(err) {
+		return
+	}
+	a.Equal("identifier not found: foobar", err.Error())
+}
+
+func runVMTests(t *testing.T, tests []vmTestCase) {
+	a := assert.New(t)
+	for _, tt := range tests {
+		testObject(a, runVM(a, tt.input), tt.expected)
+	}
+}
+
+func runVM(a *assert.Assertions, input string) object.Object {
+	program := parse(a, input)
+
+	comp := compiler.New()
+	if !a.NoError(comp.Compile(program)) {
+		return nil
+	}
+
+	vm := New(comp.Bytecode())
+	if !a.NoError(vm.Run()) {
+		return nil
+	}
+	return vm.Result()
+}
+
+func parse(a *assert.Assertions, input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	for _, msg := range p.Errors() {
+		a.Failf("parser error", msg)
+	}
+	return program
+}
+
+func testObject(a *assert.Assertions, obj object.Object, expected interface{}) {
+	switch v := expected.(type) {
+	case int:
+		testIntegerObject(a, obj, int64(v))
+	case float64:
+		result, ok := obj.(*object.Float)
+		if a.True(ok) {
+			a.InDelta(v, result.Value, 1e-9)
+		}
+	case bool:
+		result, ok := obj.(*object.Boolean)
+		if a.True(ok) {
+			a.Equal(v, result.Value)
+		}
+	case string:
+		result, ok := obj.(*object.String)
+		if a.True(ok) {
+			a.Equal(v, result.Value)
+		}
+	case []int:
+		array, ok := obj.(*object.Array)
+		if !a.True(ok) || !a.Equal(len(v), len(array.Elements)) {
+			return
+		}
+		for i, el := range v {
+			testIntegerObject(a, array.Elements[i], int64(el))
+		}
+	case *object.Error:
+		result, ok := obj.(*object.Error)
+		if a.True(ok) {
+			a.Equal(v.Message, result.Message)
+		}
+	case *object.Exit:
+		result, ok := obj.(*object.Exit)
+		if a.True(ok) {
+			a.Equal(v.Status, result.Status)
+		}
+	case nil:
+		_, ok := obj.(*object.Null)
+		a.True(ok)
+	default:
+		a.Fail("type of obj not handled")
+	}
+}
+
+func testIntegerObject(a *assert.Assertions, obj object.Object, expected int64) {
+	result, ok := obj.(*object.Integer)
+	if !a.True(ok) {
+		return
+	}
+	a.Equal(expected, result.Value)
+}